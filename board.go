@@ -0,0 +1,234 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BoardInfo describes a circuit board in the system, as reported by the
+// board-info class driver.
+type BoardInfo struct {
+	// Model is the name of the board, like "LEGO MINDSTORMS EV3".
+	Model string
+	// SerialNumber is the board's serial number, if reported.
+	SerialNumber string
+	// HardwareRevision is the board's hardware revision, if reported.
+	HardwareRevision string
+	// FirmwareVersion is the version of the board's firmware, if reported.
+	FirmwareVersion string
+	// Main is true for the main board of the system and false for
+	// auxiliary boards, like a BrickPi3 attached to a Raspberry Pi.
+	Main bool
+
+	// Fields holds every property reported by the driver, keyed by
+	// lowercase name without the "BOARD_INFO_" prefix, like "hw_rev" or
+	// "rom_rev". Identifiers that only some boards report, like ROM
+	// revisions or hardware IDs, are only found here.
+	Fields map[string]string
+}
+
+// Board returns information about the system's main board.
+func (brick *Brick) Board() (*BoardInfo, error) {
+	boards, err := brick.boards()
+	if err != nil {
+		return nil, fmt.Errorf("read board info: %w", err)
+	}
+	for _, b := range boards {
+		if b.Main {
+			return b, nil
+		}
+	}
+	if len(boards) == 0 {
+		return nil, errors.New("read board info: no boards found")
+	}
+	return boards[0], nil
+}
+
+// Platform detects the kind of system the program is running on from the
+// reported boards.
+func (brick *Brick) Platform() (Platform, error) {
+	boards, err := brick.boards()
+	if err != nil {
+		return UnknownPlatform, fmt.Errorf("detect platform: %w", err)
+	}
+	// Add-on boards determine the platform, so check auxiliary boards first.
+	sort.SliceStable(boards, func(i, j int) bool {
+		return !boards[i].Main && boards[j].Main
+	})
+	for _, b := range boards {
+		if p := platformForModel(b.Model); p != UnknownPlatform {
+			return p, nil
+		}
+	}
+	return UnknownPlatform, nil
+}
+
+func (brick *Brick) boards() ([]*BoardInfo, error) {
+	dir := filepath.Join(brick.root, "sys", "class", "board-info")
+	names, err := newDeviceDir(dir, "board").list()
+	if err != nil {
+		return nil, err
+	}
+	boards := make([]*BoardInfo, 0, len(names))
+	for _, dn := range names {
+		b, err := readBoardInfo(filepath.Join(dir, dn.name, "uevent"))
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, b)
+	}
+	return boards, nil
+}
+
+func readBoardInfo(path string) (*BoardInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b := &BoardInfo{Fields: make(map[string]string)}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		const prefix = "BOARD_INFO_"
+		line := s.Text()
+		eq := strings.IndexByte(line, '=')
+		if !strings.HasPrefix(line, prefix) || eq == -1 {
+			continue
+		}
+		key := strings.ToLower(line[len(prefix):eq])
+		value := line[eq+1:]
+		b.Fields[key] = value
+		switch key {
+		case "model":
+			b.Model = value
+		case "serial_num":
+			b.SerialNumber = value
+		case "hw_rev":
+			b.HardwareRevision = value
+		case "fw_ver":
+			b.FirmwareVersion = value
+		case "type":
+			b.Main = value == "main"
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Platform is a kind of system that ev3dev runs on.
+type Platform int
+
+// Platforms supported by ev3dev.
+const (
+	UnknownPlatform Platform = iota
+	// LEGO MINDSTORMS EV3
+	EV3
+	// Dexter Industries BrickPi and BrickPi+
+	BrickPi
+	// Dexter Industries BrickPi3
+	BrickPi3
+	// mindsensors.com PiStorms
+	PiStorms
+	// FatcatLab EVB
+	EVB
+)
+
+func platformForModel(model string) Platform {
+	switch {
+	case strings.Contains(model, "EV3"):
+		return EV3
+	case strings.Contains(model, "BrickPi3"):
+		return BrickPi3
+	case strings.Contains(model, "BrickPi"):
+		return BrickPi
+	case strings.Contains(model, "PiStorms"):
+		return PiStorms
+	case strings.Contains(model, "EVB"):
+		return EVB
+	default:
+		return UnknownPlatform
+	}
+}
+
+// String returns the name of the platform.
+func (p Platform) String() string {
+	switch p {
+	case UnknownPlatform:
+		return "unknown"
+	case EV3:
+		return "EV3"
+	case BrickPi:
+		return "BrickPi"
+	case BrickPi3:
+		return "BrickPi3"
+	case PiStorms:
+		return "PiStorms"
+	case EVB:
+		return "EVB"
+	default:
+		return fmt.Sprintf("Platform(%d)", int(p))
+	}
+}
+
+// InputPorts returns the addresses of the platform's sensor ports in the
+// order they are labeled, like "ev3-ports:in1". It returns nil for
+// UnknownPlatform. For BrickPi3, only the first board's ports are listed.
+func (p Platform) InputPorts() []string {
+	switch p {
+	case EV3:
+		return []string{"ev3-ports:in1", "ev3-ports:in2", "ev3-ports:in3", "ev3-ports:in4"}
+	case BrickPi:
+		return []string{"serial0-0:S1", "serial0-0:S2", "serial0-0:S3", "serial0-0:S4"}
+	case BrickPi3:
+		return []string{"spi0.1:S1", "spi0.1:S2", "spi0.1:S3", "spi0.1:S4"}
+	case PiStorms:
+		return []string{"pistorms:BAS1", "pistorms:BAS2", "pistorms:BBS1", "pistorms:BBS2"}
+	case EVB:
+		return []string{"evb-ports:in1", "evb-ports:in2", "evb-ports:in3", "evb-ports:in4"}
+	default:
+		return nil
+	}
+}
+
+// OutputPorts returns the addresses of the platform's motor ports in the
+// order they are labeled, like "ev3-ports:outA". It returns nil for
+// UnknownPlatform. For BrickPi3, only the first board's ports are listed.
+func (p Platform) OutputPorts() []string {
+	switch p {
+	case EV3:
+		return []string{"ev3-ports:outA", "ev3-ports:outB", "ev3-ports:outC", "ev3-ports:outD"}
+	case BrickPi:
+		return []string{"serial0-0:MA", "serial0-0:MB", "serial0-0:MC", "serial0-0:MD"}
+	case BrickPi3:
+		return []string{"spi0.1:MA", "spi0.1:MB", "spi0.1:MC", "spi0.1:MD"}
+	case PiStorms:
+		return []string{"pistorms:BAM1", "pistorms:BAM2", "pistorms:BBM1", "pistorms:BBM2"}
+	case EVB:
+		return []string{"evb-ports:outA", "evb-ports:outB", "evb-ports:outC", "evb-ports:outD"}
+	default:
+		return nil
+	}
+}
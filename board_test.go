@@ -0,0 +1,84 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"testing"
+)
+
+func TestBoard(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sys/class/board-info/board0/uevent": "BOARD_INFO_FW_VER=1.4.4\n" +
+			"BOARD_INFO_HW_REV=3\n" +
+			"BOARD_INFO_MODEL=Dexter Industries BrickPi3\n" +
+			"BOARD_INFO_SERIAL_NUM=0123456789ABCDEF\n" +
+			"BOARD_INFO_TYPE=aux\n",
+		"sys/class/board-info/board1/uevent": "BOARD_INFO_HW_REV=a02082\n" +
+			"BOARD_INFO_MODEL=Raspberry Pi 3 Model B Rev 1.2\n" +
+			"BOARD_INFO_SERIAL_NUM=00000000deadbeef\n" +
+			"BOARD_INFO_TYPE=main\n",
+	})
+	brick := newBrick(root)
+
+	b, err := brick.Board()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Main {
+		t.Error("Board().Main = false; want true")
+	}
+	if got, want := b.Model, "Raspberry Pi 3 Model B Rev 1.2"; got != want {
+		t.Errorf("Board().Model = %q; want %q", got, want)
+	}
+	if got, want := b.SerialNumber, "00000000deadbeef"; got != want {
+		t.Errorf("Board().SerialNumber = %q; want %q", got, want)
+	}
+	if got, want := b.HardwareRevision, "a02082"; got != want {
+		t.Errorf("Board().HardwareRevision = %q; want %q", got, want)
+	}
+	if got, want := b.Fields["hw_rev"], "a02082"; got != want {
+		t.Errorf("Board().Fields[\"hw_rev\"] = %q; want %q", got, want)
+	}
+
+	p, err := brick.Platform()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != BrickPi3 {
+		t.Errorf("Platform() = %v; want %v", p, BrickPi3)
+	}
+}
+
+func TestPlatformForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Platform
+	}{
+		{"LEGO MINDSTORMS EV3", EV3},
+		{"Dexter Industries BrickPi3", BrickPi3},
+		{"Dexter Industries BrickPi+", BrickPi},
+		{"mindsensors.com PiStorms", PiStorms},
+		{"FatcatLab EVB", EVB},
+		{"Raspberry Pi 3 Model B Rev 1.2", UnknownPlatform},
+	}
+	for _, test := range tests {
+		if got := platformForModel(test.model); got != test.want {
+			t.Errorf("platformForModel(%q) = %v; want %v", test.model, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Button identifies one of the EV3 brick buttons.
+type Button int
+
+// EV3 brick buttons.
+const (
+	ButtonUp Button = iota
+	ButtonDown
+	ButtonLeft
+	ButtonRight
+	ButtonEnter
+	ButtonBack
+
+	numButtons = iota
+)
+
+var buttonKeyCodes = [numButtons]uint16{
+	ButtonUp:    keyUp,
+	ButtonDown:  keyDown,
+	ButtonLeft:  keyLeft,
+	ButtonRight: keyRight,
+	ButtonEnter: keyEnter,
+	ButtonBack:  keyBackspace,
+}
+
+func buttonForKeyCode(code uint16) (Button, bool) {
+	for b, c := range buttonKeyCodes {
+		if c == code {
+			return Button(b), true
+		}
+	}
+	return 0, false
+}
+
+// String returns the lowercase name of the button.
+func (b Button) String() string {
+	switch b {
+	case ButtonUp:
+		return "up"
+	case ButtonDown:
+		return "down"
+	case ButtonLeft:
+		return "left"
+	case ButtonRight:
+		return "right"
+	case ButtonEnter:
+		return "enter"
+	case ButtonBack:
+		return "back"
+	default:
+		return fmt.Sprintf("Button(%d)", int(b))
+	}
+}
+
+// ButtonSet is a set of buttons. The zero value is the empty set.
+type ButtonSet uint8
+
+// Has reports whether b is in the set.
+func (set ButtonSet) Has(b Button) bool {
+	return b >= 0 && b < numButtons && set&(1<<uint(b)) != 0
+}
+
+// String returns the set's buttons separated by "+", like "up+enter".
+func (set ButtonSet) String() string {
+	if set == 0 {
+		return "none"
+	}
+	var names []string
+	for b := Button(0); b < numButtons; b++ {
+		if set.Has(b) {
+			names = append(names, b.String())
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// ButtonEvent is a change in a button's state.
+type ButtonEvent struct {
+	Button  Button
+	Pressed bool
+	Time    time.Time
+}
+
+// Buttons reads the state of the brick buttons.
+type Buttons struct {
+	file   *os.File
+	events chan ButtonEvent
+	done   chan struct{}
+
+	closeOnce sync.Once
+	readDone  chan struct{}
+	err       error
+}
+
+// OpenButtons opens the brick buttons input device. Once opened, button
+// presses and releases are delivered on the Events channel until the
+// Buttons is closed.
+func (brick *Brick) OpenButtons() (*Buttons, error) {
+	path := filepath.Join(brick.root, "dev", "input", "by-path", "platform-gpio_keys-event")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open buttons: %w", err)
+	}
+	return newButtons(f), nil
+}
+
+func newButtons(f *os.File) *Buttons {
+	b := &Buttons{
+		file:     f,
+		events:   make(chan ButtonEvent, 16),
+		done:     make(chan struct{}),
+		readDone: make(chan struct{}),
+	}
+	go b.read()
+	return b
+}
+
+func (b *Buttons) read() {
+	defer close(b.readDone)
+	defer close(b.events)
+	var buf [16]inputEvent
+	for {
+		n, err := readInputEvents(b.file, buf[:])
+		for _, ev := range buf[:n] {
+			if ev.typ != evKey || ev.value > 1 {
+				// Ignore everything but presses and releases.
+				// A value of 2 indicates autorepeat.
+				continue
+			}
+			button, ok := buttonForKeyCode(ev.code)
+			if !ok {
+				continue
+			}
+			select {
+			case b.events <- ButtonEvent{Button: button, Pressed: ev.value == 1, Time: ev.timestamp()}:
+			case <-b.done:
+				return
+			}
+		}
+		if err != nil {
+			select {
+			case <-b.done:
+			default:
+				if !errors.Is(err, io.EOF) {
+					b.err = fmt.Errorf("read buttons: %w", err)
+				}
+			}
+			return
+		}
+	}
+}
+
+// Events returns a channel that receives button events. The channel is
+// closed after the Buttons is closed or reading fails, after which Err
+// returns the reason.
+func (b *Buttons) Events() <-chan ButtonEvent {
+	return b.events
+}
+
+// Err returns the error that stopped event delivery, if any. It is only
+// valid to call Err after the Events channel is closed.
+func (b *Buttons) Err() error {
+	return b.err
+}
+
+// Pressed returns the set of buttons that are currently pressed.
+func (b *Buttons) Pressed() (ButtonSet, error) {
+	var keys [(keyMax + 7) / 8]byte
+	if err := ioctl(b.file, eviocgkey, unsafe.Pointer(&keys)); err != nil {
+		return 0, fmt.Errorf("read pressed buttons: %w", err)
+	}
+	var set ButtonSet
+	for button, code := range buttonKeyCodes {
+		if keys[code/8]&(1<<(code%8)) != 0 {
+			set |= 1 << uint(button)
+		}
+	}
+	return set, nil
+}
+
+// Close stops event delivery and releases the input device.
+func (b *Buttons) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.done)
+		err = b.file.Close()
+		<-b.readDone
+	})
+	if err != nil {
+		return fmt.Errorf("close buttons: %w", err)
+	}
+	return nil
+}
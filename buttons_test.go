@@ -0,0 +1,89 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestButtonEvents(t *testing.T) {
+	baseTime := time.Date(2020, time.September, 1, 12, 0, 0, 0, time.UTC)
+	events := []inputEvent{
+		{typ: evKey, code: keyUp, value: 1},
+		{typ: 0, code: 0, value: 0},
+		{typ: evKey, code: keyUp, value: 2},
+		{typ: evKey, code: keyUp, value: 0},
+		{typ: evKey, code: 999, value: 1},
+		{typ: evKey, code: keyBackspace, value: 1},
+	}
+	sb := new(strings.Builder)
+	for i := range events {
+		events[i].time = unix.NsecToTimeval(baseTime.Add(time.Duration(i) * time.Second).UnixNano())
+		sb.Write((*[unsafe.Sizeof(events[i])]byte)(unsafe.Pointer(&events[i]))[:])
+	}
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"dev/input/by-path/platform-gpio_keys-event": sb.String(),
+	})
+
+	buttons, err := newBrick(root).OpenButtons()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buttons.Close()
+	var got []ButtonEvent
+	for ev := range buttons.Events() {
+		got = append(got, ev)
+	}
+	if err := buttons.Err(); err != nil {
+		t.Error("Err() =", err)
+	}
+	want := []ButtonEvent{
+		{Button: ButtonUp, Pressed: true, Time: baseTime},
+		{Button: ButtonUp, Pressed: false, Time: baseTime.Add(3 * time.Second)},
+		{Button: ButtonBack, Pressed: true, Time: baseTime.Add(5 * time.Second)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Button != want[i].Button || got[i].Pressed != want[i].Pressed || !got[i].Time.Equal(want[i].Time) {
+			t.Errorf("events[%d] = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestButtonSetString(t *testing.T) {
+	tests := []struct {
+		set  ButtonSet
+		want string
+	}{
+		{0, "none"},
+		{1 << uint(ButtonEnter), "enter"},
+		{1<<uint(ButtonUp) | 1<<uint(ButtonBack), "up+back"},
+	}
+	for _, test := range tests {
+		if got := test.set.String(); got != test.want {
+			t.Errorf("ButtonSet(%#x).String() = %q; want %q", uint8(test.set), got, test.want)
+		}
+	}
+}
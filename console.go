@@ -0,0 +1,260 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"unicode/utf8"
+)
+
+// A Console prints lines of text onto an image, like a Display. Text that
+// goes past the bottom of the image scrolls the earlier lines up.
+//
+// If the image has a Flush method (like Display), the Console calls it
+// after every change. A Console is safe to use from multiple goroutines.
+type Console struct {
+	dst  draw.Image
+	font *Font
+
+	mu       sync.Mutex
+	lines    [][]consoleCell
+	row, col int
+	inverted bool
+	// drawn is what each cell on dst currently shows, so that redraw only
+	// has to draw the cells that changed. Cells that have never been drawn
+	// hold undrawnCell.
+	drawn [][]consoleCell
+}
+
+type consoleCell struct {
+	c        rune
+	inverted bool
+}
+
+// consoleTabWidth is the number of columns between tab stops.
+const consoleTabWidth = 4
+
+// undrawnCell is a cell value that never matches a cell in Console.lines.
+var undrawnCell = consoleCell{c: -1}
+
+// NewConsole returns a console that draws text on dst with the given font.
+// The console's size is the number of whole character cells that fit in
+// dst's bounds. NewConsole does not draw anything until text is written
+// or Clear is called.
+func NewConsole(dst draw.Image, font *Font) *Console {
+	size := dst.Bounds().Size()
+	cell := font.CellSize()
+	cols, rows := size.X/cell.X, size.Y/cell.Y
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	con := &Console{
+		dst:   dst,
+		font:  font,
+		lines: make([][]consoleCell, rows),
+		drawn: make([][]consoleCell, rows),
+	}
+	for i := range con.lines {
+		con.lines[i] = make([]consoleCell, cols)
+		con.drawn[i] = make([]consoleCell, cols)
+	}
+	con.clearLines()
+	con.invalidate()
+	return con
+}
+
+// Size returns the number of columns and rows in the console.
+func (con *Console) Size() (cols, rows int) {
+	return len(con.lines[0]), len(con.lines)
+}
+
+// SetInverted changes whether subsequently written text is drawn in
+// inverted colors, as for a selected menu item.
+func (con *Console) SetInverted(inverted bool) {
+	con.mu.Lock()
+	con.inverted = inverted
+	con.mu.Unlock()
+}
+
+// Write prints text at the cursor. Newlines move the cursor to the start of
+// the next line, carriage returns move the cursor to the start of the
+// current line, and tabs advance the cursor to the next tab stop. Text
+// that reaches the end of a line wraps onto the next line.
+func (con *Console) Write(p []byte) (int, error) {
+	con.mu.Lock()
+	defer con.mu.Unlock()
+	for i := 0; i < len(p); {
+		c, size := utf8.DecodeRune(p[i:])
+		i += size
+		con.put(c)
+	}
+	if err := con.redraw(); err != nil {
+		return len(p), fmt.Errorf("write to console: %w", err)
+	}
+	return len(p), nil
+}
+
+// WriteString is like Write, but accepts a string.
+func (con *Console) WriteString(s string) (int, error) {
+	con.mu.Lock()
+	defer con.mu.Unlock()
+	for _, c := range s {
+		con.put(c)
+	}
+	if err := con.redraw(); err != nil {
+		return len(s), fmt.Errorf("write to console: %w", err)
+	}
+	return len(s), nil
+}
+
+// SetLine replaces the text of a single row without moving the cursor or
+// scrolling. Text longer than the console's width is truncated. It is
+// intended for menus, where the selected line is inverted.
+func (con *Console) SetLine(row int, text string, inverted bool) error {
+	con.mu.Lock()
+	defer con.mu.Unlock()
+	if row < 0 || row >= len(con.lines) {
+		return fmt.Errorf("set console line %d: out of range [0, %d)", row, len(con.lines))
+	}
+	line := con.lines[row]
+	i := 0
+	for _, c := range text {
+		if i >= len(line) {
+			break
+		}
+		line[i] = consoleCell{c: c, inverted: inverted}
+		i++
+	}
+	for ; i < len(line); i++ {
+		line[i] = consoleCell{c: ' ', inverted: inverted}
+	}
+	if err := con.redraw(); err != nil {
+		return fmt.Errorf("set console line %d: %w", row, err)
+	}
+	return nil
+}
+
+// Clear erases all text and moves the cursor to the top-left corner. It
+// redraws every cell, which also erases anything else drawn on the image
+// over the console.
+func (con *Console) Clear() error {
+	con.mu.Lock()
+	defer con.mu.Unlock()
+	con.clearLines()
+	con.invalidate()
+	if err := con.redraw(); err != nil {
+		return fmt.Errorf("clear console: %w", err)
+	}
+	return nil
+}
+
+func (con *Console) clearLines() {
+	for _, line := range con.lines {
+		for i := range line {
+			line[i] = consoleCell{c: ' '}
+		}
+	}
+	con.row, con.col = 0, 0
+}
+
+// put places a single character at the cursor. The caller must be holding
+// onto con.mu.
+func (con *Console) put(c rune) {
+	cols := len(con.lines[0])
+	switch c {
+	case '\n':
+		con.newline()
+	case '\r':
+		con.col = 0
+	case '\t':
+		n := consoleTabWidth - con.col%consoleTabWidth
+		for i := 0; i < n && con.col < cols; i++ {
+			con.put(' ')
+		}
+	default:
+		if con.col >= cols {
+			con.newline()
+		}
+		con.lines[con.row][con.col] = consoleCell{c: c, inverted: con.inverted}
+		con.col++
+	}
+}
+
+// newline moves the cursor to the start of the next line, scrolling if
+// necessary. The caller must be holding onto con.mu.
+func (con *Console) newline() {
+	con.col = 0
+	if con.row < len(con.lines)-1 {
+		con.row++
+		return
+	}
+	first := con.lines[0]
+	copy(con.lines, con.lines[1:])
+	for i := range first {
+		first[i] = consoleCell{c: ' '}
+	}
+	con.lines[len(con.lines)-1] = first
+}
+
+// invalidate marks every cell as needing to be drawn. The caller must be
+// holding onto con.mu.
+func (con *Console) invalidate() {
+	for _, line := range con.drawn {
+		for i := range line {
+			line[i] = undrawnCell
+		}
+	}
+}
+
+// redraw draws the cells that changed since the last redraw onto the image
+// and flushes it if anything was drawn. The caller must be holding onto
+// con.mu.
+func (con *Console) redraw() error {
+	origin := con.dst.Bounds().Min
+	cell := con.font.CellSize()
+	changed := false
+	for row, line := range con.lines {
+		drawn := con.drawn[row]
+		for col, cc := range line {
+			if drawn[col] == cc {
+				continue
+			}
+			drawn[col] = cc
+			changed = true
+			fg, bg := color.Black, image.White
+			if cc.inverted {
+				fg, bg = color.White, image.Black
+			}
+			pt := origin.Add(image.Pt(col*cell.X, row*cell.Y))
+			con.font.drawRune(con.dst, pt, cc.c, fg, bg)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if f, ok := con.dst.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
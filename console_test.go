@@ -0,0 +1,159 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	t.Run("Size", func(t *testing.T) {
+		dst := image.NewGray(image.Rect(0, 0, 178, 128))
+		tests := []struct {
+			font       *Font
+			cols, rows int
+		}{
+			{SmallFont, 29, 16},
+			{LargeFont, 14, 8},
+		}
+		for _, test := range tests {
+			cols, rows := NewConsole(dst, test.font).Size()
+			if cols != test.cols || rows != test.rows {
+				t.Errorf("Size() = %d, %d; want %d, %d", cols, rows, test.cols, test.rows)
+			}
+		}
+	})
+	t.Run("Scroll", func(t *testing.T) {
+		dst := image.NewGray(image.Rect(0, 0, 6*10, 8*3))
+		con := NewConsole(dst, SmallFont)
+		fmt.Fprint(con, "one\ntwo\nthree\nfour\t4\nabcdefghijklm")
+		want := []string{
+			"four    4 ",
+			"abcdefghij",
+			"klm       ",
+		}
+		for row, want := range want {
+			if got := consoleLine(con, row); got != want {
+				t.Errorf("line %d = %q; want %q", row, got, want)
+			}
+		}
+	})
+	t.Run("Inverted", func(t *testing.T) {
+		dst := image.NewGray(image.Rect(0, 0, 6*10, 8*3))
+		con := NewConsole(dst, SmallFont)
+		if err := con.SetLine(1, "> Run", true); err != nil {
+			t.Fatal(err)
+		}
+		// Spacing between the characters takes the background color.
+		if got := dst.GrayAt(5, 8); got != (color.Gray{0}) {
+			t.Errorf("inverted background = %v; want black", got)
+		}
+		if got := dst.GrayAt(5, 0); got != (color.Gray{0xff}) {
+			t.Errorf("normal background = %v; want white", got)
+		}
+		if err := con.SetLine(3, "x", false); err == nil {
+			t.Error("SetLine(3, ...) on 3-row console did not return an error")
+		}
+	})
+	t.Run("RedrawsChangedCells", func(t *testing.T) {
+		dst := &countingImage{Gray: image.NewGray(image.Rect(0, 0, 6*10, 8*3))}
+		con := NewConsole(dst, SmallFont)
+		if err := con.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		if dst.flushes != 1 {
+			t.Errorf("Clear flushed %d times; want 1", dst.flushes)
+		}
+		dst.sets = 0
+		fmt.Fprint(con, "a")
+		// Only the written cell is drawn: its 6x8 background plus the
+		// glyph's pixels.
+		if max := 6*8 + 5*7; dst.sets == 0 || dst.sets > max {
+			t.Errorf("writing one character set %d pixels; want 1 to %d", dst.sets, max)
+		}
+		dst.sets = 0
+		fmt.Fprint(con, "\r")
+		if dst.sets != 0 || dst.flushes != 2 {
+			t.Errorf("writing no visible change set %d pixels and flushed %d times; want 0 and 2", dst.sets, dst.flushes)
+		}
+		if got, want := consoleLine(con, 0), "a         "; got != want {
+			t.Errorf("line 0 = %q; want %q", got, want)
+		}
+	})
+}
+
+// countingImage counts calls to Set and Flush.
+type countingImage struct {
+	*image.Gray
+	sets    int
+	flushes int
+}
+
+func (img *countingImage) Set(x, y int, c color.Color) {
+	img.sets++
+	img.Gray.Set(x, y, c)
+}
+
+func (img *countingImage) Flush() error {
+	img.flushes++
+	return nil
+}
+
+func TestFontDrawString(t *testing.T) {
+	dst := image.NewGray(image.Rect(0, 0, 12, 8))
+	end := SmallFont.DrawString(dst, image.Point{}, "|-", color.Black, color.White)
+	if want := image.Pt(12, 0); end != want {
+		t.Errorf("DrawString(...) = %v; want %v", end, want)
+	}
+	sb := new(strings.Builder)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 12; x++ {
+			if dst.GrayAt(x, y).Y == 0 {
+				sb.WriteByte('#')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	const want = "" +
+		"..#.........\n" +
+		"..#.........\n" +
+		"..#.........\n" +
+		"..#...#####.\n" +
+		"..#.........\n" +
+		"..#.........\n" +
+		"..#.........\n" +
+		"............\n"
+	if got := sb.String(); got != want {
+		t.Errorf("drawn text:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func consoleLine(con *Console, row int) string {
+	con.mu.Lock()
+	defer con.mu.Unlock()
+	sb := new(strings.Builder)
+	for _, cc := range con.lines[row] {
+		sb.WriteRune(cc.c)
+	}
+	return sb.String()
+}
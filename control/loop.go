@@ -0,0 +1,111 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package control
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"zombiezen.com/go/ev3dev"
+)
+
+// A Loop runs a PID controller at a fixed rate, reading a measurement from
+// Input and sending the controller's output to Output.
+type Loop struct {
+	Controller *PID
+	Input      func() (float64, error)
+	Output     func(float64) error
+	// Interval is the time between updates. Zero means 20ms.
+	Interval time.Duration
+
+	mu       sync.Mutex
+	setpoint float64
+}
+
+// Setpoint returns the loop's target measurement.
+func (l *Loop) Setpoint() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.setpoint
+}
+
+// SetSetpoint changes the loop's target measurement. It is safe to call
+// while the loop is running.
+func (l *Loop) SetSetpoint(setpoint float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setpoint = setpoint
+}
+
+// Run updates the controller every interval until ctx is done or Input or
+// Output returns an error. Run does not change the output when it returns,
+// so the caller should stop any motors afterward.
+func (l *Loop) Run(ctx context.Context) error {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = 20 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	return l.run(ctx, ticker.C, time.Now)
+}
+
+func (l *Loop) run(ctx context.Context, tick <-chan time.Time, now func() time.Time) error {
+	l.Controller.Reset()
+	last := now()
+	for {
+		y, err := l.Input()
+		if err != nil {
+			return err
+		}
+		t := now()
+		u := l.Controller.Update(l.Setpoint(), y, t.Sub(last))
+		last = t
+		if err := l.Output(u); err != nil {
+			return err
+		}
+		select {
+		case <-tick:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SensorInput returns a Loop input that reads the i'th value of a sensor.
+func SensorInput(s *ev3dev.Sensor, i int) func() (float64, error) {
+	return func() (float64, error) {
+		v, err := s.Value(i)
+		if err != nil {
+			return 0, err
+		}
+		return v.Float64(), nil
+	}
+}
+
+// MotorSpeedOutput returns a Loop output that runs a motor at a speed in
+// tacho counts per second, rounded to the nearest count. Speeds beyond
+// the motor's limits end the loop with an error, so set the controller's
+// output range within the motor's MaxSpeed or give the motor limits with
+// the ClampToLimits policy.
+func MotorSpeedOutput(m *ev3dev.TachoMotor) func(float64) error {
+	return func(u float64) error {
+		return m.Run(ev3dev.TachoSpeed(math.Round(u)))
+	}
+}
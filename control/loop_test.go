@@ -0,0 +1,100 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package control
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLoop(t *testing.T) {
+	errDone := errors.New("out of measurements")
+	measurements := []float64{0, 5, 10}
+	var outputs []float64
+	l := &Loop{
+		Controller: NewPID(1, 1, 0),
+		Input: func() (float64, error) {
+			if len(measurements) == 0 {
+				return 0, errDone
+			}
+			y := measurements[0]
+			measurements = measurements[1:]
+			return y, nil
+		},
+		Output: func(u float64) error {
+			outputs = append(outputs, u)
+			return nil
+		},
+		Interval: 100 * time.Millisecond,
+	}
+	l.SetSetpoint(10)
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	now := func() time.Time {
+		t := start.Add(time.Duration(calls) * 100 * time.Millisecond)
+		calls++
+		return t
+	}
+	tick := make(chan time.Time)
+	close(tick)
+	err := l.run(context.Background(), tick, now)
+	if !errors.Is(err, errDone) {
+		t.Errorf("run(...) = %v; want %v", err, errDone)
+	}
+	// P + I with 100ms between updates.
+	want := []float64{10 + 1, 5 + 1.5, 0 + 1.5}
+	if len(outputs) != len(want) {
+		t.Fatalf("outputs = %v; want %v", outputs, want)
+	}
+	for i := range want {
+		if math.Abs(outputs[i]-want[i]) > 1e-9 {
+			t.Errorf("outputs = %v; want %v", outputs, want)
+			break
+		}
+	}
+}
+
+func TestLoopCancel(t *testing.T) {
+	l := &Loop{
+		Controller: NewPID(1, 0, 0),
+		Input:      func() (float64, error) { return 0, nil },
+		Output:     func(float64) error { return nil },
+		Interval:   time.Hour,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run(canceled ctx) = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestLoopZeroInterval(t *testing.T) {
+	l := &Loop{
+		Controller: NewPID(1, 0, 0),
+		Input:      func() (float64, error) { return 0, nil },
+		Output:     func(float64) error { return nil },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run(ctx) = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package control provides feedback controllers for driving motors from
+// sensor readings.
+package control
+
+import (
+	"math"
+	"time"
+
+	"zombiezen.com/go/ev3dev/fixedpoint"
+)
+
+// PID is a proportional-integral-derivative controller. The zero value is a
+// controller with all gains set to zero; use NewPID to get the usual
+// setpoint weights.
+//
+// The controller computes
+//
+//	P = Kp * (SetpointWeightP*r - y)
+//	I = Ki * ∫ (r - y) dt
+//	D = Kd * d/dt (SetpointWeightD*r - y)
+//
+// where r is the setpoint and y is the measurement, and outputs P + I + D,
+// clamped to [OutputMin, OutputMax].
+//
+// A PID is not safe to use from multiple goroutines.
+type PID struct {
+	Kp, Ki, Kd float64
+
+	// SetpointWeightP and SetpointWeightD scale the setpoint in the
+	// proportional and derivative terms. Weights below 1 reduce overshoot
+	// when the setpoint changes suddenly without changing how disturbances
+	// are rejected. NewPID sets SetpointWeightP to 1 and SetpointWeightD to
+	// 0, so that setpoint steps do not cause derivative kicks.
+	SetpointWeightP float64
+	SetpointWeightD float64
+
+	// If OutputMin < OutputMax, the output is clamped to
+	// [OutputMin, OutputMax] and the integral stops accumulating while the
+	// output is saturated (anti-windup).
+	OutputMin, OutputMax float64
+
+	// DerivativeFilter is the time constant of a first-order low-pass
+	// filter applied to the derivative term, which otherwise amplifies
+	// measurement noise. Zero disables the filter.
+	DerivativeFilter time.Duration
+
+	integral    float64
+	prevDInput  float64
+	deriv       float64
+	initialized bool
+}
+
+// NewPID returns a controller with the given gains and standard setpoint
+// weights.
+func NewPID(kp, ki, kd float64) *PID {
+	return &PID{
+		Kp:              kp,
+		Ki:              ki,
+		Kd:              kd,
+		SetpointWeightP: 1,
+	}
+}
+
+// Reset clears the controller's integral and derivative state, as when
+// restarting control after a pause.
+func (c *PID) Reset() {
+	c.integral = 0
+	c.prevDInput = 0
+	c.deriv = 0
+	c.initialized = false
+}
+
+// Update computes the controller output given the time elapsed since the
+// last update. The first update after creation or Reset has no derivative
+// term.
+func (c *PID) Update(setpoint, measurement float64, dt time.Duration) float64 {
+	secs := dt.Seconds()
+	e := setpoint - measurement
+	p := c.Kp * (c.SetpointWeightP*setpoint - measurement)
+
+	dInput := c.SetpointWeightD*setpoint - measurement
+	if c.initialized && secs > 0 {
+		raw := (dInput - c.prevDInput) / secs
+		if tf := c.DerivativeFilter.Seconds(); tf > 0 {
+			c.deriv += secs / (tf + secs) * (raw - c.deriv)
+		} else {
+			c.deriv = raw
+		}
+	}
+	c.prevDInput = dInput
+	c.initialized = true
+	d := c.Kd * c.deriv
+
+	integral := c.integral
+	if secs > 0 {
+		integral += c.Ki * e * secs
+	}
+	u := p + integral + d
+	if c.OutputMin < c.OutputMax {
+		switch {
+		case u > c.OutputMax:
+			u = c.OutputMax
+			if e > 0 {
+				// Integrating would push further into saturation.
+				integral = c.integral
+			}
+		case u < c.OutputMin:
+			u = c.OutputMin
+			if e < 0 {
+				integral = c.integral
+			}
+		}
+	}
+	c.integral = integral
+	return u
+}
+
+// valueDecimals is the number of decimal places in UpdateValue's output.
+const valueDecimals = 3
+
+// UpdateValue is like Update, but operates on fixed-point values, like
+// those returned by Sensor.Value. The output has three decimal places.
+func (c *PID) UpdateValue(setpoint, measurement fixedpoint.Value, dt time.Duration) fixedpoint.Value {
+	u := c.Update(setpoint.Float64(), measurement.Float64(), dt)
+	scaled := math.Round(u * math.Pow10(valueDecimals))
+	if scaled > math.MaxInt32 {
+		scaled = math.MaxInt32
+	} else if scaled < math.MinInt32 {
+		scaled = math.MinInt32
+	}
+	return fixedpoint.FromInt(int32(scaled)).Shift10(-valueDecimals)
+}
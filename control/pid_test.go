@@ -0,0 +1,141 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package control
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"zombiezen.com/go/ev3dev/fixedpoint"
+)
+
+func TestPID(t *testing.T) {
+	type step struct {
+		setpoint, measurement float64
+		dt                    time.Duration
+		want                  float64
+	}
+	tests := []struct {
+		name  string
+		pid   *PID
+		steps []step
+	}{
+		{
+			name: "Proportional",
+			pid:  NewPID(2, 0, 0),
+			steps: []step{
+				{10, 4, time.Second, 12},
+				{10, 12, time.Second, -4},
+			},
+		},
+		{
+			name: "Integral",
+			pid:  NewPID(0, 1, 0),
+			steps: []step{
+				{1, 0, time.Second, 1},
+				{1, 0, 500 * time.Millisecond, 1.5},
+				{1, 2, time.Second, 0.5},
+			},
+		},
+		{
+			name: "DerivativeOnMeasurement",
+			pid:  NewPID(0, 0, 1),
+			steps: []step{
+				{0, 0, time.Second, 0},
+				{0, 1, 500 * time.Millisecond, -2},
+				// A setpoint step does not kick the derivative.
+				{100, 1, time.Second, 0},
+			},
+		},
+		{
+			name: "DerivativeOnError",
+			pid:  &PID{Kd: 1, SetpointWeightD: 1},
+			steps: []step{
+				{0, 0, time.Second, 0},
+				{10, 0, time.Second, 10},
+			},
+		},
+		{
+			name: "SetpointWeight",
+			pid:  &PID{Kp: 1, SetpointWeightP: 0.5},
+			steps: []step{
+				{10, 0, time.Second, 5},
+				{10, 5, time.Second, 0},
+			},
+		},
+		{
+			name: "DerivativeFilter",
+			pid:  &PID{Kd: 1, DerivativeFilter: time.Second},
+			steps: []step{
+				{0, 0, time.Second, 0},
+				{0, -4, time.Second, 2},
+				{0, -4, time.Second, 1},
+			},
+		},
+		{
+			name: "Clamp",
+			pid:  &PID{Kp: 1, SetpointWeightP: 1, OutputMin: -1, OutputMax: 1},
+			steps: []step{
+				{10, 0, time.Second, 1},
+				{-10, 0, time.Second, -1},
+				{0.5, 0, time.Second, 0.5},
+			},
+		},
+		{
+			name: "AntiWindup",
+			pid:  &PID{Ki: 1, OutputMin: -1, OutputMax: 1},
+			steps: []step{
+				{10, 0, time.Second, 1},
+				{10, 0, time.Second, 1},
+				{10, 0, time.Second, 1},
+				// Without anti-windup, the integral would be 30 here and
+				// the output would stay saturated.
+				{0, 0.5, time.Second, -0.5},
+			},
+		},
+	}
+	const eps = 1e-9
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, s := range test.steps {
+				got := test.pid.Update(s.setpoint, s.measurement, s.dt)
+				if math.Abs(got-s.want) > eps {
+					t.Errorf("Update(%g, %g, %v) #%d = %g; want %g", s.setpoint, s.measurement, s.dt, i+1, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPIDReset(t *testing.T) {
+	pid := NewPID(0, 1, 1)
+	pid.Update(1, 0, time.Second)
+	pid.Update(1, 0.5, time.Second)
+	pid.Reset()
+	if got := pid.Update(1, 1, time.Second); got != 0 {
+		t.Errorf("Update after Reset = %g; want 0", got)
+	}
+}
+
+func TestPIDUpdateValue(t *testing.T) {
+	pid := NewPID(0.5, 0, 0)
+	got := pid.UpdateValue(fixedpoint.FromInt(10), fixedpoint.FromInt(4).Shift10(-1), time.Second)
+	if want := 4.8; math.Abs(got.Float64()-want) > 1e-9 {
+		t.Errorf("UpdateValue(10, 0.4, 1s) = %v; want %g", got, want)
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Framebuffer ioctl requests. See <linux/fb.h>.
+const (
+	fbioGetVScreenInfo = 0x4600
+	fbioGetFScreenInfo = 0x4602
+)
+
+// Framebuffer visuals. See <linux/fb.h>.
+const (
+	fbVisualMono01    = 0
+	fbVisualMono10    = 1
+	fbVisualTrueColor = 2
+)
+
+// fbFixScreenInfo is struct fb_fix_screeninfo.
+type fbFixScreenInfo struct {
+	id           [16]byte
+	smemStart    uint
+	smemLen      uint32
+	typ          uint32
+	typeAux      uint32
+	visual       uint32
+	xPanStep     uint16
+	yPanStep     uint16
+	yWrapStep    uint16
+	lineLength   uint32
+	mmioStart    uint
+	mmioLen      uint32
+	accel        uint32
+	capabilities uint16
+	reserved     [2]uint16
+}
+
+type fbBitfield struct {
+	offset   uint32
+	length   uint32
+	msbRight uint32
+}
+
+// fbVarScreenInfo is struct fb_var_screeninfo.
+type fbVarScreenInfo struct {
+	xRes, yRes               uint32
+	xResVirtual, yResVirtual uint32
+	xOffset, yOffset         uint32
+	bitsPerPixel             uint32
+	grayscale                uint32
+	red, green, blue, transp fbBitfield
+	nonstd                   uint32
+	activate                 uint32
+	height, width            uint32
+	accelFlags               uint32
+	pixclock                 uint32
+	leftMargin, rightMargin  uint32
+	upperMargin, lowerMargin uint32
+	hsyncLen, vsyncLen       uint32
+	sync                     uint32
+	vmode                    uint32
+	rotate                   uint32
+	colorspace               uint32
+	reserved                 [4]uint32
+}
+
+// monoPalette is the color model of monochrome displays.
+var monoPalette = color.Palette{color.Black, color.White}
+
+// A Display is a framebuffer device, like the EV3's LCD. It implements
+// draw.Image, but drawing only changes an off-screen buffer: the screen is
+// updated when Flush is called.
+//
+// Monochrome framebuffers (the EV3 layout) and 16 and 32 bits per pixel
+// true color framebuffers are supported.
+type Display struct {
+	file *os.File
+	buf  []byte
+
+	// mu protects the fields below it, which can be accessed from the VT's
+	// signal handler.
+	mu sync.Mutex
+	// mem is the memory-mapped framebuffer.
+	mem []byte
+	// front is the last flushed buffer, used to redraw the screen when
+	// switching back to a VT. It is nil unless a VT is set.
+	front []byte
+	vt    *VT
+
+	width  int
+	height int
+	stride int
+	bpp    int
+	// For monochrome displays, whether a set bit is white.
+	setIsWhite             bool
+	red, green, blue, alph fbBitfield
+}
+
+// OpenDisplay opens the brick's primary framebuffer, /dev/fb0.
+func (brick *Brick) OpenDisplay() (*Display, error) {
+	f, err := os.OpenFile(filepath.Join(brick.root, "dev", "fb0"), os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open display: %w", err)
+	}
+	fix := new(fbFixScreenInfo)
+	if err := ioctl(f, fbioGetFScreenInfo, unsafe.Pointer(fix)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open display: get fixed screen info: %w", err)
+	}
+	vinfo := new(fbVarScreenInfo)
+	if err := ioctl(f, fbioGetVScreenInfo, unsafe.Pointer(vinfo)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open display: get variable screen info: %w", err)
+	}
+	mem, err := unix.Mmap(int(f.Fd()), 0, int(fix.smemLen), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open display: %w", err)
+	}
+	d, err := newDisplay(mem, fix, vinfo)
+	if err != nil {
+		unix.Munmap(mem)
+		f.Close()
+		return nil, fmt.Errorf("open display: %w", err)
+	}
+	d.file = f
+	return d, nil
+}
+
+// newDisplay returns a display that flushes to mem.
+func newDisplay(mem []byte, fix *fbFixScreenInfo, vinfo *fbVarScreenInfo) (*Display, error) {
+	d := &Display{
+		mem:    mem,
+		width:  int(vinfo.xRes),
+		height: int(vinfo.yRes),
+		stride: int(fix.lineLength),
+		bpp:    int(vinfo.bitsPerPixel),
+	}
+	switch {
+	case d.bpp == 1 && (fix.visual == fbVisualMono01 || fix.visual == fbVisualMono10):
+		d.setIsWhite = fix.visual == fbVisualMono10
+	case (d.bpp == 16 || d.bpp == 32) && fix.visual == fbVisualTrueColor:
+		d.red = vinfo.red
+		d.green = vinfo.green
+		d.blue = vinfo.blue
+		d.alph = vinfo.transp
+	default:
+		return nil, fmt.Errorf("unsupported framebuffer format (%d bits per pixel, visual %d)", d.bpp, fix.visual)
+	}
+	if d.width <= 0 || d.height <= 0 || d.stride*8 < d.width*d.bpp {
+		return nil, fmt.Errorf("invalid framebuffer geometry %dx%d with line length %d", d.width, d.height, d.stride)
+	}
+	if len(mem) < d.stride*d.height {
+		return nil, errors.New("framebuffer memory smaller than screen")
+	}
+	d.buf = make([]byte, d.stride*d.height)
+	return d, nil
+}
+
+// SetVT ties the display to a virtual terminal. While the terminal is in
+// the background, Flush does not change the screen, and when the terminal
+// returns to the foreground, the last flushed image is redrawn. Closing the
+// display closes the terminal.
+func (d *Display) SetVT(vt *VT) {
+	d.mu.Lock()
+	d.vt = vt
+	if d.front == nil {
+		d.front = make([]byte, len(d.buf))
+		copy(d.front, d.mem)
+	}
+	d.mu.Unlock()
+	vt.setOnAcquire(d.redraw)
+	vt.setOnRelease(d.waitForFlush)
+}
+
+// waitForFlush waits for a Flush in progress to finish. Flush checks whether
+// the terminal is active while holding d.mu, so once the terminal is marked
+// inactive and waitForFlush returns, no Flush writes to the screen.
+func (d *Display) waitForFlush() {
+	d.mu.Lock()
+	d.mu.Unlock()
+}
+
+// redraw copies the last flushed image to the screen.
+func (d *Display) redraw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mem != nil {
+		copy(d.mem, d.front)
+	}
+}
+
+// Close releases the framebuffer and the display's virtual terminal, if
+// any. It does not clear the screen.
+func (d *Display) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var firstErr error
+	if d.vt != nil {
+		firstErr = d.vt.Close()
+		d.vt = nil
+	}
+	if d.mem != nil {
+		if err := unix.Munmap(d.mem); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		d.mem = nil
+	}
+	if d.file != nil {
+		if err := d.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("close display: %w", firstErr)
+	}
+	return nil
+}
+
+// Flush copies the off-screen buffer to the screen.
+func (d *Display) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mem == nil {
+		return errors.New("flush display: closed")
+	}
+	if d.vt == nil {
+		copy(d.mem, d.buf)
+		return nil
+	}
+	copy(d.front, d.buf)
+	if d.vt.Active() {
+		copy(d.mem, d.front)
+	}
+	return nil
+}
+
+// ColorModel returns the display's color model. Monochrome displays use a
+// black and white palette.
+func (d *Display) ColorModel() color.Model {
+	if d.bpp == 1 {
+		return monoPalette
+	}
+	return color.RGBAModel
+}
+
+// Bounds returns the size of the screen in pixels. The top-left pixel is
+// always (0, 0).
+func (d *Display) Bounds() image.Rectangle {
+	return image.Rect(0, 0, d.width, d.height)
+}
+
+// At returns the color of the pixel at (x, y) in the off-screen buffer.
+func (d *Display) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(d.Bounds())) {
+		return color.RGBA{}
+	}
+	switch d.bpp {
+	case 1:
+		// The EV3 stores the leftmost pixel in the least significant bit.
+		set := d.buf[y*d.stride+x/8]&(1<<uint(x%8)) != 0
+		if set == d.setIsWhite {
+			return color.White
+		}
+		return color.Black
+	case 16:
+		i := y*d.stride + x*2
+		return d.decode(uint32(d.buf[i]) | uint32(d.buf[i+1])<<8)
+	default:
+		i := y*d.stride + x*4
+		return d.decode(uint32(d.buf[i]) | uint32(d.buf[i+1])<<8 | uint32(d.buf[i+2])<<16 | uint32(d.buf[i+3])<<24)
+	}
+}
+
+// Set changes the color of the pixel at (x, y) in the off-screen buffer.
+func (d *Display) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(d.Bounds())) {
+		return
+	}
+	switch d.bpp {
+	case 1:
+		white := color.GrayModel.Convert(c).(color.Gray).Y >= 0x80
+		i := y*d.stride + x/8
+		mask := byte(1 << uint(x%8))
+		if white == d.setIsWhite {
+			d.buf[i] |= mask
+		} else {
+			d.buf[i] &^= mask
+		}
+	case 16:
+		v := d.encode(c)
+		i := y*d.stride + x*2
+		d.buf[i] = byte(v)
+		d.buf[i+1] = byte(v >> 8)
+	default:
+		v := d.encode(c)
+		i := y*d.stride + x*4
+		d.buf[i] = byte(v)
+		d.buf[i+1] = byte(v >> 8)
+		d.buf[i+2] = byte(v >> 16)
+		d.buf[i+3] = byte(v >> 24)
+	}
+}
+
+// encode converts a color to a true color pixel value.
+func (d *Display) encode(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+	v := encodeChannel(r, d.red) | encodeChannel(g, d.green) | encodeChannel(b, d.blue)
+	// The screen is opaque, so if there is an alpha channel, fill it.
+	v |= encodeChannel(0xffff, d.alph)
+	return v
+}
+
+// decode converts a true color pixel value to a color.
+func (d *Display) decode(v uint32) color.RGBA {
+	return color.RGBA{
+		R: decodeChannel(v, d.red),
+		G: decodeChannel(v, d.green),
+		B: decodeChannel(v, d.blue),
+		A: 0xff,
+	}
+}
+
+func encodeChannel(x uint32, f fbBitfield) uint32 {
+	if f.length == 0 {
+		return 0
+	}
+	return (x >> (16 - f.length)) << f.offset
+}
+
+func decodeChannel(v uint32, f fbBitfield) uint8 {
+	if f.length == 0 {
+		return 0
+	}
+	x := (v >> f.offset) & (1<<f.length - 1)
+	// Replicate the high bits into the low bits so that full intensity
+	// maps to 0xff.
+	x <<= 16 - f.length
+	for shift := f.length; shift < 16; shift *= 2 {
+		x |= x >> shift
+	}
+	return uint8(x >> 8)
+}
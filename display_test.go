@@ -0,0 +1,202 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+var _ draw.Image = (*Display)(nil)
+
+func TestFramebufferStructSizes(t *testing.T) {
+	is64Bit := unsafe.Sizeof(uintptr(0)) == 8
+	wantFix := uintptr(68)
+	if is64Bit {
+		wantFix = 80
+	}
+	if got := unsafe.Sizeof(fbFixScreenInfo{}); got != wantFix {
+		t.Errorf("sizeof(struct fb_fix_screeninfo) = %d; want %d", got, wantFix)
+	}
+	if got, want := unsafe.Sizeof(fbVarScreenInfo{}), uintptr(160); got != want {
+		t.Errorf("sizeof(struct fb_var_screeninfo) = %d; want %d", got, want)
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	tests := []struct {
+		name  string
+		fix   fbFixScreenInfo
+		vinfo fbVarScreenInfo
+
+		// Pixel at (9, 1) set to white.
+		wantMem map[int]byte
+	}{
+		{
+			name:  "EV3",
+			fix:   fbFixScreenInfo{visual: fbVisualMono01, lineLength: 24},
+			vinfo: fbVarScreenInfo{xRes: 178, yRes: 128, bitsPerPixel: 1},
+			wantMem: map[int]byte{
+				24 + 1: 0xff &^ (1 << 1),
+			},
+		},
+		{
+			name: "RGB565",
+			fix:  fbFixScreenInfo{visual: fbVisualTrueColor, lineLength: 178 * 2},
+			vinfo: fbVarScreenInfo{
+				xRes:         178,
+				yRes:         128,
+				bitsPerPixel: 16,
+				red:          fbBitfield{offset: 11, length: 5},
+				green:        fbBitfield{offset: 5, length: 6},
+				blue:         fbBitfield{offset: 0, length: 5},
+			},
+			wantMem: map[int]byte{
+				178*2 + 9*2:     0xff,
+				178*2 + 9*2 + 1: 0xff,
+			},
+		},
+		{
+			name: "XRGB8888",
+			fix:  fbFixScreenInfo{visual: fbVisualTrueColor, lineLength: 178 * 4},
+			vinfo: fbVarScreenInfo{
+				xRes:         178,
+				yRes:         128,
+				bitsPerPixel: 32,
+				red:          fbBitfield{offset: 16, length: 8},
+				green:        fbBitfield{offset: 8, length: 8},
+				blue:         fbBitfield{offset: 0, length: 8},
+			},
+			wantMem: map[int]byte{
+				178*4 + 9*4:     0xff,
+				178*4 + 9*4 + 1: 0xff,
+				178*4 + 9*4 + 2: 0xff,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mem := make([]byte, int(test.fix.lineLength)*int(test.vinfo.yRes))
+			d, err := newDisplay(mem, &test.fix, &test.vinfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := d.Bounds(), image.Rect(0, 0, 178, 128); got != want {
+				t.Errorf("Bounds() = %v; want %v", got, want)
+			}
+			draw.Draw(d, d.Bounds(), image.Black, image.Point{}, draw.Src)
+			d.Set(9, 1, color.White)
+			if err := d.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			for i, b := range mem {
+				want, ok := test.wantMem[i]
+				if !ok && test.vinfo.bitsPerPixel == 1 {
+					// Black is a set bit, except for the padding
+					// at the end of each line.
+					switch i % 24 {
+					case 22:
+						want = 0x03
+					case 23:
+						want = 0
+					default:
+						want = 0xff
+					}
+				}
+				if b != want {
+					t.Errorf("mem[%d] = %#02x; want %#02x", i, b, want)
+				}
+			}
+			gotWhite := color.RGBAModel.Convert(d.At(9, 1)).(color.RGBA)
+			if want := (color.RGBA{0xff, 0xff, 0xff, 0xff}); gotWhite != want {
+				t.Errorf("At(9, 1) = %v; want %v", gotWhite, want)
+			}
+			gotBlack := color.RGBAModel.Convert(d.At(10, 1)).(color.RGBA)
+			if want := (color.RGBA{0, 0, 0, 0xff}); gotBlack != want {
+				t.Errorf("At(10, 1) = %v; want %v", gotBlack, want)
+			}
+		})
+	}
+}
+
+func TestDisplayVT(t *testing.T) {
+	fix := &fbFixScreenInfo{visual: fbVisualMono10, lineLength: 1}
+	vinfo := &fbVarScreenInfo{xRes: 8, yRes: 1, bitsPerPixel: 1}
+	mem := make([]byte, 1)
+	d, err := newDisplay(mem, fix, vinfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vt := new(VT)
+	d.SetVT(vt)
+
+	// Flushing while the terminal is in the background leaves the screen alone.
+	d.Set(0, 0, color.White)
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if mem[0] != 0 {
+		t.Errorf("after Flush in background, mem[0] = %#02x; want 0x00", mem[0])
+	}
+
+	// Switching back draws the last flushed image.
+	vt.mu.Lock()
+	vt.active = true
+	onAcquire := vt.onAcquire
+	vt.mu.Unlock()
+	onAcquire()
+	if mem[0] != 0x01 {
+		t.Errorf("after switching back, mem[0] = %#02x; want 0x01", mem[0])
+	}
+
+	d.Set(1, 0, color.White)
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if mem[0] != 0x03 {
+		t.Errorf("after Flush in foreground, mem[0] = %#02x; want 0x03", mem[0])
+	}
+
+	// Switching away waits for a Flush in progress.
+	d.mu.Lock()
+	released := make(chan struct{})
+	go func() {
+		vt.release()
+		close(released)
+	}()
+	select {
+	case <-released:
+		t.Error("release returned during Flush")
+	case <-time.After(50 * time.Millisecond):
+	}
+	d.mu.Unlock()
+	<-released
+	if vt.Active() {
+		t.Error("Active() = true after release")
+	}
+	d.Set(2, 0, color.White)
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if mem[0] != 0x03 {
+		t.Errorf("after Flush in background, mem[0] = %#02x; want 0x03", mem[0])
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// A DifferentialDrive is a robot base with two independently driven wheels,
+// like a tank. Lengths are in meters, linear speeds are in meters per
+// second and angles are in degrees, with positive angles turning
+// counter-clockwise (to the left).
+//
+// Commands return as soon as the motors have been started. Use Wait to
+// block until a command that stops on its own has finished.
+type DifferentialDrive struct {
+	left, right   *TachoMotor
+	group         *MotorGroup
+	wheelDiameter float64
+	trackWidth    float64
+}
+
+// NewDifferentialDrive returns a drive for the given motors. wheelDiameter
+// is the diameter of the wheels and trackWidth is the distance between the
+// centers of the wheels' contact patches.
+func NewDifferentialDrive(left, right *TachoMotor, wheelDiameter, trackWidth float64) (*DifferentialDrive, error) {
+	switch {
+	case left == nil || right == nil:
+		return nil, errors.New("new differential drive: nil motor")
+	case left == right:
+		return nil, errors.New("new differential drive: left and right are the same motor")
+	case !(wheelDiameter > 0):
+		return nil, fmt.Errorf("new differential drive: invalid wheel diameter %g", wheelDiameter)
+	case !(trackWidth > 0):
+		return nil, fmt.Errorf("new differential drive: invalid track width %g", trackWidth)
+	}
+	return &DifferentialDrive{
+		left:          left,
+		right:         right,
+		group:         NewMotorGroup(left, right),
+		wheelDiameter: wheelDiameter,
+		trackWidth:    trackWidth,
+	}, nil
+}
+
+// Motors returns the left and right motors.
+func (d *DifferentialDrive) Motors() (left, right *TachoMotor) {
+	return d.left, d.right
+}
+
+// countsPerMeter returns the number of tacho counts for a wheel to roll one
+// meter.
+func (d *DifferentialDrive) countsPerMeter(m *TachoMotor) float64 {
+	return float64(m.CountPerRotation()) / (math.Pi * d.wheelDiameter)
+}
+
+func (d *DifferentialDrive) delta(m *TachoMotor, distance float64) TachoDelta {
+	return Meters(d.countsPerMeter(m), distance)
+}
+
+func (d *DifferentialDrive) speed(m *TachoMotor, speed float64) TachoSpeed {
+	return MetersPerSecond(d.countsPerMeter(m), speed)
+}
+
+// OnForDistance drives straight for the given distance at the given speed.
+// Negative distances drive backward.
+func (d *DifferentialDrive) OnForDistance(distance, speed float64, action StopAction) error {
+	if err := d.travel(distance, distance, speed, speed, action); err != nil {
+		return fmt.Errorf("drive for distance: %w", err)
+	}
+	return nil
+}
+
+// Turn rotates the robot in place by the given angle, with the wheels
+// moving at the given speed.
+func (d *DifferentialDrive) Turn(angle, speed float64, action StopAction) error {
+	arc := degreesToRadians(angle) * d.trackWidth / 2
+	if err := d.travel(-arc, arc, speed, speed, action); err != nil {
+		return fmt.Errorf("turn: %w", err)
+	}
+	return nil
+}
+
+// Arc drives along a circle with the given radius, measured to the center
+// of the robot, until the robot has turned by the given angle. The center
+// of the robot moves at the given speed. Positive radii put the center of
+// the circle to the robot's left and negative radii to its right. A zero
+// radius turns in place.
+func (d *DifferentialDrive) Arc(radius, angle, speed float64, action StopAction) error {
+	if radius == 0 {
+		if err := d.Turn(angle, speed, action); err != nil {
+			return fmt.Errorf("arc: %w", err)
+		}
+		return nil
+	}
+	theta := degreesToRadians(angle)
+	leftRadius := radius - d.trackWidth/2
+	rightRadius := radius + d.trackWidth/2
+	// Driving forward along a left-hand circle is a counter-clockwise turn,
+	// but along a right-hand circle is a clockwise turn.
+	if radius < 0 {
+		theta = -theta
+	}
+	leftSpeed := math.Abs(speed * leftRadius / radius)
+	rightSpeed := math.Abs(speed * rightRadius / radius)
+	if err := d.travel(theta*leftRadius, theta*rightRadius, leftSpeed, rightSpeed, action); err != nil {
+		return fmt.Errorf("arc: %w", err)
+	}
+	return nil
+}
+
+// travel moves each wheel by a distance at a speed.
+func (d *DifferentialDrive) travel(leftDistance, rightDistance, leftSpeed, rightSpeed float64, action StopAction) error {
+	if !(leftSpeed > 0 || leftDistance == 0) || !(rightSpeed > 0 || rightDistance == 0) {
+		return fmt.Errorf("invalid speed %g", math.Max(leftSpeed, rightSpeed))
+	}
+	deltas := []TachoDelta{d.delta(d.left, leftDistance), d.delta(d.right, rightDistance)}
+	params := []*TachoMotorParams{
+		{Speed: d.speed(d.left, leftSpeed), StopAction: action},
+		{Speed: d.speed(d.right, rightSpeed), StopAction: action},
+	}
+	for i, p := range params {
+		if p.Speed == 0 {
+			// Rounded to zero; keep the motor from reusing its last speed.
+			p.Speed = 1
+			if deltas[i] == 0 {
+				p.Speed = 0
+			}
+		}
+	}
+	return d.group.RunToDelta(deltas, params...)
+}
+
+// Tank runs each wheel at the given speed until another command is given.
+// Negative speeds run backward.
+func (d *DifferentialDrive) Tank(leftSpeed, rightSpeed float64) error {
+	speeds := []TachoSpeed{d.speed(d.left, leftSpeed), d.speed(d.right, rightSpeed)}
+	if err := d.group.Run(speeds); err != nil {
+		return fmt.Errorf("tank drive: %w", err)
+	}
+	return nil
+}
+
+// Steering runs the wheels until another command is given, turning by an
+// amount given as a percentage in [-100, 100]. Zero drives straight, 50
+// stops the left wheel so the robot pivots to the left, and 100 turns in
+// place to the left; negative values turn right. The faster wheel runs at
+// the given speed.
+func (d *DifferentialDrive) Steering(percent, speed float64) error {
+	if percent < -100 || percent > 100 {
+		return fmt.Errorf("steering drive: percent %g out of range [-100, 100]", percent)
+	}
+	inner := speed * (50 - math.Abs(percent)) / 50
+	leftSpeed, rightSpeed := speed, speed
+	if percent > 0 {
+		leftSpeed = inner
+	} else {
+		rightSpeed = inner
+	}
+	if err := d.Tank(leftSpeed, rightSpeed); err != nil {
+		return fmt.Errorf("steering drive: %w", err)
+	}
+	return nil
+}
+
+// Stop stops both wheels with the given action. As with MotorGroup.Stop,
+// it never waits for an unplugged wheel to reconnect and still stops the
+// other wheel if one fails.
+func (d *DifferentialDrive) Stop(action StopAction) error {
+	return d.group.Stop(action)
+}
+
+// Wait blocks until both wheels have stopped or ctx is done.
+func (d *DifferentialDrive) Wait(ctx context.Context) error {
+	return d.group.Wait(ctx)
+}
+
+func degreesToRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
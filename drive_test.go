@@ -0,0 +1,208 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDifferentialDrive(t *testing.T) {
+	// A wheel diameter of 1/π gives a circumference of 1 meter, so the fake
+	// motors turn 360 counts per meter.
+	const (
+		wheelDiameter = 1 / math.Pi
+		trackWidth    = 0.5
+	)
+	tests := []struct {
+		name string
+		f    func(d *DifferentialDrive) error
+		want map[string]string
+	}{
+		{
+			name: "OnForDistance",
+			f:    func(d *DifferentialDrive) error { return d.OnForDistance(0.5, 0.25, Brake) },
+			want: map[string]string{
+				"motor0/command":     "run-to-rel-pos",
+				"motor0/position_sp": "180",
+				"motor0/speed_sp":    "90",
+				"motor0/stop_action": "brake",
+				"motor1/command":     "run-to-rel-pos",
+				"motor1/position_sp": "180",
+				"motor1/speed_sp":    "90",
+				"motor1/stop_action": "brake",
+			},
+		},
+		{
+			name: "Backward",
+			f:    func(d *DifferentialDrive) error { return d.OnForDistance(-0.5, 0.25, Brake) },
+			want: map[string]string{
+				"motor0/position_sp": "-180",
+				"motor0/speed_sp":    "90",
+				"motor1/position_sp": "-180",
+				"motor1/speed_sp":    "90",
+			},
+		},
+		{
+			name: "Turn",
+			f:    func(d *DifferentialDrive) error { return d.Turn(90, 0.25, Hold) },
+			want: map[string]string{
+				"motor0/position_sp": "-141",
+				"motor0/speed_sp":    "90",
+				"motor1/position_sp": "141",
+				"motor1/speed_sp":    "90",
+			},
+		},
+		{
+			name: "ArcLeft",
+			f:    func(d *DifferentialDrive) error { return d.Arc(0.5, 90, 0.2, Coast) },
+			want: map[string]string{
+				"motor0/position_sp": "141",
+				"motor0/speed_sp":    "36",
+				"motor1/position_sp": "424",
+				"motor1/speed_sp":    "108",
+			},
+		},
+		{
+			name: "ArcRight",
+			f:    func(d *DifferentialDrive) error { return d.Arc(-0.5, 90, 0.2, Coast) },
+			want: map[string]string{
+				"motor0/position_sp": "424",
+				"motor0/speed_sp":    "108",
+				"motor1/position_sp": "141",
+				"motor1/speed_sp":    "36",
+			},
+		},
+		{
+			name: "Tank",
+			f:    func(d *DifferentialDrive) error { return d.Tank(-0.1, 0.1) },
+			want: map[string]string{
+				"motor0/command":  "run-forever",
+				"motor0/speed_sp": "-36",
+				"motor1/command":  "run-forever",
+				"motor1/speed_sp": "36",
+			},
+		},
+		{
+			name: "SteeringPivot",
+			f:    func(d *DifferentialDrive) error { return d.Steering(50, 0.25) },
+			want: map[string]string{
+				"motor0/speed_sp": "0",
+				"motor1/speed_sp": "90",
+			},
+		},
+		{
+			name: "SteeringSpin",
+			f:    func(d *DifferentialDrive) error { return d.Steering(-100, 0.25) },
+			want: map[string]string{
+				"motor0/speed_sp": "90",
+				"motor1/speed_sp": "-90",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			left, right, motorsDir := newFakeMotorGroup(t)
+			d, err := NewDifferentialDrive(left, right, wheelDiameter, trackWidth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.f(d); err != nil {
+				t.Fatal(err)
+			}
+			for fname, want := range test.want {
+				if got := readFile(t, motorsDir, fname); got != want {
+					t.Errorf("%s = %q; want %q", fname, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDifferentialDriveErrors(t *testing.T) {
+	left, right, _ := newFakeMotorGroup(t)
+	if _, err := NewDifferentialDrive(left, left, 0.056, 0.12); err == nil {
+		t.Error("NewDifferentialDrive with same motor did not return an error")
+	}
+	if _, err := NewDifferentialDrive(left, right, 0, 0.12); err == nil {
+		t.Error("NewDifferentialDrive with zero wheel diameter did not return an error")
+	}
+	d, err := NewDifferentialDrive(left, right, 0.056, 0.12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.OnForDistance(1, -0.1, Brake); err == nil {
+		t.Error("OnForDistance with negative speed did not return an error")
+	}
+	if err := d.Steering(101, 0.1); err == nil {
+		t.Error("Steering(101, ...) did not return an error")
+	}
+}
+
+func TestDifferentialDriveStopReconnecting(t *testing.T) {
+	left, right, motorsDir := newFakeMotorGroup(t)
+	left.SetReconnectTimeout(10 * time.Second)
+	d, err := NewDifferentialDrive(left, right, 0.056, 0.12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Steering(0, 0.1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+	// Fake files stay writable after they are removed, so simulate a
+	// command on the left wheel failing.
+	errc := make(chan error, 1)
+	go func() {
+		left.mu.Lock()
+		defer left.mu.Unlock()
+		calls := 0
+		errc <- left.retry(func() error {
+			calls++
+			if calls == 1 {
+				return errRemoved
+			}
+			return nil
+		})
+	}()
+	waitForReconnecting(t, left)
+
+	done := make(chan error, 1)
+	go func() { done <- d.Stop(Brake) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrDeviceRemoved) {
+			t.Errorf("Stop(Brake) = %v; want %v", err, ErrDeviceRemoved)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked while the left wheel was reconnecting")
+	}
+	if got, want := readFile(t, motorsDir, "motor1/command"), "stop"; got != want {
+		t.Errorf("motor1/command = %q; want %q", got, want)
+	}
+
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor2", "ev3-ports:outA", 0))
+	if err := <-errc; err != nil {
+		t.Error("retry:", err)
+	}
+}
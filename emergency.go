@@ -0,0 +1,108 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrEmergencyStopped is returned for motor commands that were dropped
+// because EmergencyStop was called while they were being issued.
+var ErrEmergencyStopped = errors.New("emergency stopped")
+
+// addMotor registers a newly opened motor for emergency stops. The caller
+// must be holding onto devs.mu.
+func (devs *devices) addMotor(m *TachoMotor) {
+	if devs.openMotors == nil {
+		devs.openMotors = make(map[*TachoMotor]struct{})
+	}
+	devs.openMotors[m] = struct{}{}
+}
+
+// EmergencyStop stops every motor opened from the brick that has not been
+// closed, using the given stop action. The motors are stopped in parallel.
+// EmergencyStop does not wait for commands in progress on other goroutines
+// or for unplugged motors to reconnect. Instead, those commands are dropped
+// and fail with an error wrapping ErrEmergencyStopped, so that they cannot
+// start the motors again. Commands issued after EmergencyStop returns run
+// as usual. It returns the first error
+// encountered, but always attempts to stop every motor.
+func (brick *Brick) EmergencyStop(action StopAction) error {
+	brick.devices.mu.Lock()
+	motors := make([]*TachoMotor, 0, len(brick.devices.openMotors))
+	for m := range brick.devices.openMotors {
+		motors = append(motors, m)
+	}
+	brick.devices.mu.Unlock()
+
+	errs := make([]error, len(motors))
+	var wg sync.WaitGroup
+	wg.Add(len(motors))
+	for i, m := range motors {
+		go func(i int, m *TachoMotor) {
+			defer wg.Done()
+			errs[i] = m.emergencyStop(action)
+		}(i, m)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StopOnSignal calls EmergencyStop when the program receives one of the
+// given signals, or SIGINT, SIGTERM or SIGHUP if none are given. After the
+// motors are stopped and the package's other cleanup for the signal has
+// finished, like restoring an open VT, the signal's default action is
+// performed, which usually terminates the program. Calling the returned
+// function stops listening for the signals.
+//
+// Signals that the program ignores, like SIGHUP when run under nohup, are
+// left ignored. Performing the default action also removes the program's
+// own signal.Notify registrations for the signal, so a program with its own
+// graceful shutdown should call EmergencyStop from its handler instead of
+// using StopOnSignal.
+//
+// Motors are not stopped by os.Exit, since it does not run deferred calls
+// or signal handlers. Close motors before exiting instead.
+func (brick *Brick) StopOnSignal(action StopAction, sigs ...os.Signal) (cancel func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGHUP}
+	}
+	return exitSignals.add(func(os.Signal) {
+		brick.EmergencyStop(action)
+	}, sigs...)
+}
+
+// StopOnPanic calls EmergencyStop if the calling goroutine is panicking and
+// then continues panicking. It must be deferred directly, usually at the
+// top of main and of each goroutine that commands motors:
+//
+//	defer ev3dev.System.StopOnPanic(ev3dev.Brake)
+func (brick *Brick) StopOnPanic(action StopAction) {
+	if v := recover(); v != nil {
+		brick.EmergencyStop(action)
+		panic(v)
+	}
+}
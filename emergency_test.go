@@ -0,0 +1,169 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEmergencyStop(t *testing.T) {
+	root := t.TempDir()
+	motorsDir := filepath.Join(root, "sys", "class", "tacho-motor")
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor0", "ev3-ports:outA", 0))
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor1", "ev3-ports:outB", 0))
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor2", "ev3-ports:outC", 0))
+	brick := newBrick(root)
+	a := openFakeTachoMotor(t, brick, "ev3-ports:outA")
+	b := openFakeTachoMotor(t, brick, "ev3-ports:outB")
+	c := openFakeTachoMotor(t, brick, "ev3-ports:outC")
+	for _, m := range []*TachoMotor{a, b, c} {
+		if err := m.Run(100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := brick.EmergencyStop(Brake); err != nil {
+		t.Error("EmergencyStop:", err)
+	}
+	for _, name := range []string{"motor0", "motor1"} {
+		if got, want := readFile(t, motorsDir, name+"/command"), "stop"; got != want {
+			t.Errorf("%s/command = %q; want %q", name, got, want)
+		}
+		if got, want := readFile(t, motorsDir, name+"/stop_action"), "brake"; got != want {
+			t.Errorf("%s/stop_action = %q; want %q", name, got, want)
+		}
+	}
+	// Closed motors are reset rather than stopped.
+	if got, want := readFile(t, motorsDir, "motor2/command"), "reset"; got != want {
+		t.Errorf("motor2/command = %q; want %q", got, want)
+	}
+}
+
+func TestEmergencyStopDoesNotWaitForLock(t *testing.T) {
+	m, motorsDir := newFakeTachoMotor(t, "ev3-ports:outA")
+	brick := &Brick{devices: m.devices}
+	if err := m.Run(100); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a command or reconnect in progress on another goroutine.
+	m.mu.Lock()
+	done := make(chan error, 1)
+	go func() { done <- brick.EmergencyStop(Brake) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("EmergencyStop:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("EmergencyStop blocked on the motor's lock")
+	}
+	m.mu.Unlock()
+	if got, want := readFile(t, motorsDir, "motor0/command"), "stop"; got != want {
+		t.Errorf("motor0/command = %q; want %q", got, want)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/stop_action"), "brake"; got != want {
+		t.Errorf("motor0/stop_action = %q; want %q", got, want)
+	}
+}
+
+func TestEmergencyStopDropsCommandInProgress(t *testing.T) {
+	m, motorsDir := newFakeTachoMotor(t, "ev3-ports:outA")
+	brick := &Brick{devices: m.devices}
+
+	// Stop the motor while the command is being prepared.
+	m.mu.Lock()
+	err := m.retryCommand(func() (string, error) {
+		if err := brick.EmergencyStop(Brake); err != nil {
+			t.Error("EmergencyStop:", err)
+		}
+		return m.prepareRun(100)
+	})
+	m.mu.Unlock()
+	if !errors.Is(err, ErrEmergencyStopped) {
+		t.Errorf("retryCommand(...) = %v; want %v", err, ErrEmergencyStopped)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "stop"; got != want {
+		t.Errorf("motor0/command = %q; want %q", got, want)
+	}
+
+	// Later commands run as usual.
+	if err := m.Run(100); err != nil {
+		t.Fatal("Run after EmergencyStop:", err)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "run-forever"; got != want {
+		t.Errorf("motor0/command = %q; want %q", got, want)
+	}
+}
+
+func TestEmergencyStopDropsGroupCommandInProgress(t *testing.T) {
+	a, b, motorsDir := newFakeMotorGroup(t)
+	g := NewMotorGroup(a, b)
+
+	// Stop b after a's command has been prepared.
+	err := g.do("test", func(i int, m *TachoMotor) (string, error) {
+		if m == b {
+			if err := b.emergencyStop(Brake); err != nil {
+				t.Error("emergencyStop:", err)
+			}
+		}
+		return m.prepareRun(100)
+	})
+	if !errors.Is(err, ErrEmergencyStopped) {
+		t.Errorf("do(...) = %v; want %v", err, ErrEmergencyStopped)
+	}
+	want := map[string]string{
+		"motor0/command": "run-forever",
+		"motor1/command": "stop",
+	}
+	for fname, want := range want {
+		if got := readFile(t, motorsDir, fname); got != want {
+			t.Errorf("%s = %q; want %q", fname, got, want)
+		}
+	}
+}
+
+func TestStopOnPanic(t *testing.T) {
+	m, motorsDir := newFakeTachoMotor(t, "ev3-ports:outA")
+	brick := &Brick{devices: m.devices}
+
+	const msg = "bork"
+	var got interface{}
+	func() {
+		defer func() { got = recover() }()
+		defer brick.StopOnPanic(Hold)
+		if err := m.Run(100); err != nil {
+			t.Fatal(err)
+		}
+		panic(msg)
+	}()
+	if got != msg {
+		t.Errorf("recovered %v; want %q", got, msg)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "stop"; got != want {
+		t.Errorf("command = %q; want %q", got, want)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/stop_action"), "hold"; got != want {
+		t.Errorf("stop_action = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// Errors that can be tested for with errors.Is. Errors returned by this
+// package wrap these to add context about the operation that failed.
+var (
+	// ErrNotFound indicates that no port or device exists at an address.
+	ErrNotFound = errors.New("not found")
+	// ErrDeviceRemoved indicates that a device was unplugged or
+	// reconfigured after it was opened. The device must be opened again.
+	ErrDeviceRemoved = errors.New("device removed")
+	// ErrPortBusy indicates that a port or device is already in use, either
+	// by an earlier call in this program or by the kernel.
+	ErrPortBusy = errors.New("port busy")
+	// ErrUnsupported indicates that a device does not support an operation
+	// or setting.
+	ErrUnsupported = errors.New("unsupported")
+)
+
+// errnoError wraps an error from a system call so that errors.Is matches
+// both the errno and the corresponding package error.
+type errnoError struct {
+	err error
+}
+
+// wrapErrno returns err wrapped so that errors.Is matches the package error
+// corresponding to its errno, if any.
+func wrapErrno(err error) error {
+	if errnoSentinel(err) == nil {
+		return err
+	}
+	return errnoError{err}
+}
+
+func errnoSentinel(err error) error {
+	switch {
+	case errors.Is(err, unix.ENODEV):
+		return ErrDeviceRemoved
+	case errors.Is(err, unix.EBUSY):
+		return ErrPortBusy
+	case errors.Is(err, unix.EOPNOTSUPP):
+		return ErrUnsupported
+	default:
+		return nil
+	}
+}
+
+func (e errnoError) Error() string {
+	return e.err.Error()
+}
+
+func (e errnoError) Unwrap() error {
+	return e.err
+}
+
+func (e errnoError) Is(target error) bool {
+	return target == errnoSentinel(e.err)
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestWrapErrno(t *testing.T) {
+	tests := []struct {
+		errno unix.Errno
+		want  error
+	}{
+		{unix.ENODEV, ErrDeviceRemoved},
+		{unix.EBUSY, ErrPortBusy},
+		{unix.EOPNOTSUPP, ErrUnsupported},
+	}
+	sentinels := []error{ErrNotFound, ErrDeviceRemoved, ErrPortBusy, ErrUnsupported}
+	for _, test := range tests {
+		err := fmt.Errorf("read motor position: %w", wrapErrno(&os.PathError{
+			Op:   "read",
+			Path: "/sys/class/tacho-motor/motor0/position",
+			Err:  test.errno,
+		}))
+		if !errors.Is(err, test.errno) {
+			t.Errorf("errors.Is(%v, %v) = false; want true", err, test.errno)
+		}
+		for _, sentinel := range sentinels {
+			if got, want := errors.Is(err, sentinel), sentinel == test.want; got != want {
+				t.Errorf("errors.Is(%v, %v) = %t; want %t", err, sentinel, got, want)
+			}
+		}
+	}
+
+	err := wrapErrno(unix.EINVAL)
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			t.Errorf("errors.Is(%v, %v) = true; want false", err, sentinel)
+		}
+	}
+}
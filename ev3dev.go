@@ -26,14 +26,19 @@ import (
 
 // Brick is the root handle to the EV3Dev drivers.
 type Brick struct {
-	ports   deviceDir
+	root    string
 	devices *devices
 }
 
 type devices struct {
 	mu          sync.Mutex
+	ports       deviceDir
 	tachoMotors deviceDir
 	sensors     deviceDir
+
+	// openMotors is the set of motors that have been opened and not yet
+	// closed, used for emergency stops.
+	openMotors map[*TachoMotor]struct{}
 }
 
 func newBrick(root string) *Brick {
@@ -41,8 +46,9 @@ func newBrick(root string) *Brick {
 	tachoMotorsDir := newDeviceDir(filepath.Join(root, "sys", "class", "tacho-motor"), "motor")
 	sensorsDir := newDeviceDir(filepath.Join(root, "sys", "class", "lego-sensor"), "sensor")
 	return &Brick{
-		ports: *portsDir,
+		root: root,
 		devices: &devices{
+			ports:       *portsDir,
 			tachoMotors: *tachoMotorsDir,
 			sensors:     *sensorsDir,
 		},
@@ -50,13 +56,16 @@ func newBrick(root string) *Brick {
 }
 
 // PortByAddress searches for the port with the given address. Subsequent calls
-// for the same address will return an error.
+// for the same address will return an error wrapping ErrPortBusy until the
+// port is closed.
 func (brick *Brick) PortByAddress(addr string) (*Port, error) {
 	a, err := newAddress(addr)
 	if err != nil {
 		return nil, fmt.Errorf("find port %q: %w", addr, err)
 	}
-	path, err := brick.ports.findByAddress(a)
+	brick.devices.mu.Lock()
+	path, err := brick.devices.ports.findByAddress(a)
+	brick.devices.mu.Unlock()
 	if err != nil {
 		return nil, fmt.Errorf("find port %q: %w", addr, err)
 	}
@@ -74,6 +83,44 @@ type Port struct {
 	path    string
 	addr    address
 	devices *devices
+
+	// closed is protected by devices.mu.
+	closed bool
+}
+
+// Reset returns the port to its default automatic mode, in which the kernel
+// detects and loads drivers for devices as they are plugged in. Sensors and
+// motors opened from the port stop working.
+func (p *Port) Reset() error {
+	f, err := openAttrWrite(filepath.Join(p.path, "mode"))
+	if err != nil {
+		return fmt.Errorf("reset port %q: %w", p.addr, err)
+	}
+	err = writeAttr(f, []byte("auto"))
+	closeErr := f.Close()
+	if err != nil {
+		return fmt.Errorf("reset port %q: %w", p.addr, err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("reset port %q: %w", p.addr, closeErr)
+	}
+	return nil
+}
+
+// Close returns the port to the brick so that a later call to PortByAddress
+// can find it again. Close does not change the port's mode: call Reset
+// first to hand the port back to the kernel's automatic detection. Sensors
+// and motors opened from the port are not affected and must be closed
+// separately.
+func (p *Port) Close() error {
+	p.devices.mu.Lock()
+	defer p.devices.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("close port %q: already closed", p.addr)
+	}
+	p.closed = true
+	p.devices.ports.release(p.path, p.addr)
+	return nil
 }
 
 // Addr returns the port address, like "spi0.1:S3".
@@ -85,7 +132,7 @@ func (p *Port) Addr() string {
 func (p *Port) OpenSensor(typ SensorType) (*Sensor, error) {
 	mode := typ.portMode()
 	if len(mode) == 0 {
-		return nil, fmt.Errorf("open sensor for port %q: invalid type %v", p.addr, typ)
+		return nil, fmt.Errorf("open sensor for port %q: type %v: %w", p.addr, typ, ErrUnsupported)
 	}
 
 	modeFile, err := openAttrWrite(filepath.Join(p.path, "mode"))
@@ -126,6 +173,8 @@ func (p *Port) OpenSensor(typ SensorType) (*Sensor, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open sensor for port %q: %w", p.addr, err)
 	}
+	s.addr = p.addr
+	s.devices = p.devices
 	return s, nil
 }
 
@@ -156,5 +205,8 @@ func (p *Port) OpenTachoMotor() (*TachoMotor, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open tacho motor for port %q: %w", p.addr, err)
 	}
+	m.addr = p.addr
+	m.devices = p.devices
+	p.devices.addMotor(m)
 	return m, nil
 }
@@ -0,0 +1,59 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPortClose(t *testing.T) {
+	const addr = "ev3-ports:in1"
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sys/class/lego-port/port0/address": addr + "\n",
+		"sys/class/lego-port/port0/mode":    "nxt-analog\n",
+	})
+	brick := newBrick(root)
+	p, err := brick.PortByAddress(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := brick.PortByAddress(addr); !errors.Is(err, ErrPortBusy) {
+		t.Errorf("PortByAddress(%q) while open error = %v; want %v", addr, err, ErrPortBusy)
+	}
+	if err := p.Reset(); err != nil {
+		t.Error("Reset:", err)
+	}
+	if got, want := readFile(t, root, "sys/class/lego-port/port0/mode"), "auto"; got != want {
+		t.Errorf("mode = %q; want %q", got, want)
+	}
+	if err := p.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	if err := p.Close(); err == nil {
+		t.Error("second Close did not return an error")
+	}
+
+	p2, err := brick.PortByAddress(addr)
+	if err != nil {
+		t.Fatal("PortByAddress after Close:", err)
+	}
+	if got := p2.Addr(); got != addr {
+		t.Errorf("Addr() = %q; want %q", got, addr)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A MotorGroup commands several motors together. Each command writes the
+// set points of every motor first and then writes the motors' commands back
+// to back, so the motors start as close to simultaneously as the kernel
+// allows.
+//
+// A MotorGroup is safe to use from multiple goroutines, and its motors can
+// still be used individually. While a group command is being issued, the
+// group holds all of its motors' locks, except while waiting for an
+// unplugged motor to reconnect.
+type MotorGroup struct {
+	motors []*TachoMotor
+	// lockOrder is motors sorted by sequence number, so that groups with
+	// overlapping motors cannot deadlock.
+	lockOrder []*TachoMotor
+}
+
+// waitPollInterval is how often MotorGroup.Wait checks whether the motors
+// have stopped.
+const waitPollInterval = 10 * time.Millisecond
+
+// NewMotorGroup returns a group of the given motors. Commands that take
+// per-motor arguments use the same order as motors. NewMotorGroup panics if
+// a motor is nil or appears more than once.
+func NewMotorGroup(motors ...*TachoMotor) *MotorGroup {
+	g := &MotorGroup{
+		motors:    append([]*TachoMotor(nil), motors...),
+		lockOrder: append([]*TachoMotor(nil), motors...),
+	}
+	for _, m := range g.lockOrder {
+		if m == nil {
+			panic("ev3dev: nil motor in group")
+		}
+	}
+	sort.Slice(g.lockOrder, func(i, j int) bool {
+		return g.lockOrder[i].seq < g.lockOrder[j].seq
+	})
+	for i, m := range g.lockOrder {
+		if i > 0 && g.lockOrder[i-1] == m {
+			panic("ev3dev: motor appears more than once in group")
+		}
+	}
+	return g
+}
+
+// Motors returns the motors in the group.
+func (g *MotorGroup) Motors() []*TachoMotor {
+	return append([]*TachoMotor(nil), g.motors...)
+}
+
+// Run starts each motor running at the corresponding speed. len(speeds)
+// must equal the number of motors in the group.
+func (g *MotorGroup) Run(speeds []TachoSpeed) error {
+	if len(speeds) != len(g.motors) {
+		return fmt.Errorf("run motor group: %d speeds for %d motors", len(speeds), len(g.motors))
+	}
+	return g.do("run motor group", func(i int, m *TachoMotor) (string, error) {
+		return m.prepareRun(speeds[i])
+	})
+}
+
+// RunToPosition runs each motor to the corresponding absolute position.
+// params may be empty to use the defaults, have a single element shared by
+// all motors, or have one element per motor.
+func (g *MotorGroup) RunToPosition(positions []TachoPosition, params ...*TachoMotorParams) error {
+	const op = "run motor group to position"
+	if len(positions) != len(g.motors) {
+		return fmt.Errorf("%s: %d positions for %d motors", op, len(positions), len(g.motors))
+	}
+	if err := g.checkParams(params); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return g.do(op, func(i int, m *TachoMotor) (string, error) {
+		return m.prepareRunToPosition(positions[i], groupParams(params, i))
+	})
+}
+
+// RunToDelta runs each motor by the corresponding distance from its current
+// position. params is interpreted as in RunToPosition.
+func (g *MotorGroup) RunToDelta(deltas []TachoDelta, params ...*TachoMotorParams) error {
+	const op = "run motor group to position"
+	if len(deltas) != len(g.motors) {
+		return fmt.Errorf("%s: %d deltas for %d motors", op, len(deltas), len(g.motors))
+	}
+	if err := g.checkParams(params); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return g.do(op, func(i int, m *TachoMotor) (string, error) {
+		return m.prepareRunToDelta(deltas[i], groupParams(params, i))
+	})
+}
+
+// RunTimed runs all of the motors for the same duration. params is
+// interpreted as in RunToPosition.
+func (g *MotorGroup) RunTimed(t time.Duration, params ...*TachoMotorParams) error {
+	const op = "run motor group for time"
+	if err := g.checkParams(params); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return g.do(op, func(i int, m *TachoMotor) (string, error) {
+		return m.prepareRunTimed(t, groupParams(params, i))
+	})
+}
+
+// Stop stops all of the motors with the given action. Stop never waits for
+// an unplugged motor to reconnect, and a motor that fails to stop does not
+// keep the rest of the group from stopping. It returns the first error
+// encountered.
+func (g *MotorGroup) Stop(action StopAction) error {
+	return g.doEach("stop motor group", func(i int, m *TachoMotor) (string, error) {
+		return m.prepareStop(action)
+	})
+}
+
+// Reset stops all of the motors and resets their options. Like Stop, it
+// never waits for an unplugged motor to reconnect and always attempts to
+// reset every motor.
+func (g *MotorGroup) Reset() error {
+	return g.doEach("reset motor group", func(i int, m *TachoMotor) (string, error) {
+		m.lastSpeed = 0
+		m.polarity = NormalPolarity
+		return "reset", nil
+	})
+}
+
+// Wait blocks until none of the motors are running or ctx is done. Motors
+// that are holding their position count as stopped.
+func (g *MotorGroup) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		running := false
+		for i, m := range g.motors {
+			state, err := m.State()
+			if err != nil {
+				return fmt.Errorf("wait for motor group: motor %d: %w", i, err)
+			}
+			if state.Has(MotorRunning) && !state.Has(MotorHolding) {
+				running = true
+				break
+			}
+		}
+		if !running {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("wait for motor group: %w", ctx.Err())
+		}
+	}
+}
+
+func (g *MotorGroup) checkParams(params []*TachoMotorParams) error {
+	if len(params) > 1 && len(params) != len(g.motors) {
+		return fmt.Errorf("%d params for %d motors", len(params), len(g.motors))
+	}
+	return nil
+}
+
+func groupParams(params []*TachoMotorParams, i int) *TachoMotorParams {
+	switch len(params) {
+	case 0:
+		return nil
+	case 1:
+		return params[0]
+	default:
+		return params[i]
+	}
+}
+
+// do issues a command to every motor in the group. If a motor has been
+// unplugged and has reconnection enabled, do waits for it to reconnect
+// while holding only that motor's lock, so that the rest of the group can
+// still be stopped in the meantime, and then tries the command once more.
+// Motors that are emergency stopped after do starts are not started again.
+func (g *MotorGroup) do(op string, prepare func(i int, m *TachoMotor) (string, error)) error {
+	epochs := make([]uint64, len(g.motors))
+	for i, m := range g.motors {
+		epochs[i] = m.currentStopEpoch()
+	}
+	removed, err := g.doLocked(op, epochs, prepare)
+	if removed == nil {
+		return err
+	}
+	if rerr := removed.motor.reconnectSince(removed.reconnects); rerr != nil {
+		return fmt.Errorf("%w (reconnect: %v)", err, rerr)
+	}
+	_, err = g.doLocked(op, epochs, prepare)
+	return err
+}
+
+// doEach is like doLocked, but it does not stop at the first motor that
+// fails. Motors that fail to prepare or are reconnecting are skipped and the
+// rest of the group still receives its commands. doEach never waits for a
+// motor to reconnect.
+func (g *MotorGroup) doEach(op string, prepare func(i int, m *TachoMotor) (string, error)) error {
+	for _, m := range g.lockOrder {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	var firstErr error
+	commands := make([]string, len(g.motors))
+	for i, m := range g.motors {
+		var err error
+		if m.reconnector.reconnecting() {
+			err = ErrDeviceRemoved
+		} else {
+			commands[i], err = prepare(i, m)
+		}
+		if err != nil {
+			commands[i] = ""
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: motor %d: %w", op, i, err)
+			}
+		}
+	}
+	for i, m := range g.motors {
+		if commands[i] == "" {
+			continue
+		}
+		if err := m.writeCommand(commands[i]); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: motor %d: %w", op, i, err)
+		}
+	}
+	return firstErr
+}
+
+// A removedMotor is a motor that a group command found unplugged.
+type removedMotor struct {
+	motor *TachoMotor
+	// reconnects is the motor's reconnect count when it was found unplugged.
+	reconnects int
+}
+
+// doLocked locks all of the motors, calls prepare for each motor, then
+// writes the returned commands. If prepare fails because a motor with
+// reconnection enabled was unplugged, doLocked returns the motor so that the
+// caller can reconnect it. Motors that are already reconnecting are treated
+// as unplugged. A motor's command is dropped if the motor has been
+// emergency stopped since its entry in epochs was taken.
+func (g *MotorGroup) doLocked(op string, epochs []uint64, prepare func(i int, m *TachoMotor) (string, error)) (*removedMotor, error) {
+	for _, m := range g.lockOrder {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	commands := make([]string, len(g.motors))
+	for i, m := range g.motors {
+		var err error
+		if m.reconnector.reconnecting() {
+			err = ErrDeviceRemoved
+		} else {
+			commands[i], err = prepare(i, m)
+		}
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s: motor %d: %w", op, i, err)
+		if m.devices == nil || m.reconnectTimeout <= 0 || !errors.Is(err, ErrDeviceRemoved) {
+			return nil, err
+		}
+		return &removedMotor{m, m.reconnector.n}, err
+	}
+	var firstErr error
+	for i, m := range g.motors {
+		// Keep going so that one failure doesn't leave the rest of the
+		// group in an inconsistent state.
+		if err := m.writeCommandSince(commands[i], epochs[i]); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: motor %d: %w", op, i, err)
+		}
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,298 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newFakeMotorGroup(t *testing.T) (a, b *TachoMotor, motorsDir string) {
+	root := t.TempDir()
+	motorsDir = filepath.Join(root, "sys", "class", "tacho-motor")
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor0", "ev3-ports:outA", 0))
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor1", "ev3-ports:outB", 0))
+	brick := newBrick(root)
+	a = openFakeTachoMotor(t, brick, "ev3-ports:outA")
+	b = openFakeTachoMotor(t, brick, "ev3-ports:outB")
+	return a, b, motorsDir
+}
+
+func TestMotorGroup(t *testing.T) {
+	a, b, motorsDir := newFakeMotorGroup(t)
+	g := NewMotorGroup(a, b)
+
+	err := g.RunToDelta([]TachoDelta{360, -180}, &TachoMotorParams{Speed: 400, StopAction: Hold})
+	if err != nil {
+		t.Fatal("RunToDelta:", err)
+	}
+	want := map[string]string{
+		"motor0/position_sp": "360",
+		"motor0/speed_sp":    "400",
+		"motor0/stop_action": "hold",
+		"motor0/command":     "run-to-rel-pos",
+		"motor1/position_sp": "-180",
+		"motor1/speed_sp":    "400",
+		"motor1/stop_action": "hold",
+		"motor1/command":     "run-to-rel-pos",
+	}
+	for fname, want := range want {
+		if got := readFile(t, motorsDir, fname); got != want {
+			t.Errorf("%s = %q; want %q", fname, got, want)
+		}
+	}
+
+	err = g.RunToPosition([]TachoPosition{10, 20}, &TachoMotorParams{Speed: 100}, &TachoMotorParams{Speed: 200})
+	if err != nil {
+		t.Fatal("RunToPosition:", err)
+	}
+	if got, want := readFile(t, motorsDir, "motor1/speed_sp"), "200"; got != want {
+		t.Errorf("motor1/speed_sp = %q; want %q", got, want)
+	}
+
+	if err := g.Stop(Brake); err != nil {
+		t.Fatal("Stop:", err)
+	}
+	for _, name := range []string{"motor0", "motor1"} {
+		if got, want := readFile(t, motorsDir, name+"/command"), "stop"; got != want {
+			t.Errorf("%s/command = %q; want %q", name, got, want)
+		}
+	}
+
+	if err := g.Run([]TachoSpeed{100}); err == nil {
+		t.Error("Run with 1 speed for 2 motors did not return an error")
+	}
+	params := &TachoMotorParams{}
+	if err := g.RunTimed(time.Second, params, params, params); err == nil {
+		t.Error("RunTimed with 3 params for 2 motors did not return an error")
+	}
+}
+
+func TestMotorGroupRejectsBeforeStarting(t *testing.T) {
+	a, b, motorsDir := newFakeMotorGroup(t)
+	if err := b.SetLimits(TachoMotorLimits{MaxSpeed: 100}); err != nil {
+		t.Fatal(err)
+	}
+	g := NewMotorGroup(a, b)
+	if err := g.Run([]TachoSpeed{500, 500}); err == nil {
+		t.Error("Run beyond limits did not return an error")
+	}
+	for _, name := range []string{"motor0", "motor1"} {
+		if got := readFile(t, motorsDir, name+"/command"); got != "" {
+			t.Errorf("%s/command = %q; want \"\"", name, got)
+		}
+	}
+}
+
+func TestMotorGroupWait(t *testing.T) {
+	a, b, motorsDir := newFakeMotorGroup(t)
+	writeFiles(t, motorsDir, map[string]string{
+		"motor0/state": "holding\n",
+		"motor1/state": "running ramping\n",
+	})
+	g := NewMotorGroup(a, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	err := g.Wait(ctx)
+	cancel()
+	if err == nil {
+		t.Fatal("Wait returned before motors stopped")
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		ioutil.WriteFile(filepath.Join(motorsDir, "motor1", "state"), []byte("\n"), 0666)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := g.Wait(ctx); err != nil {
+		t.Error("Wait:", err)
+	}
+}
+
+func TestMotorGroupOverlapping(t *testing.T) {
+	a, b, _ := newFakeMotorGroup(t)
+	g1 := NewMotorGroup(a, b)
+	g2 := NewMotorGroup(b, a)
+	var wg sync.WaitGroup
+	for _, g := range []*MotorGroup{g1, g2} {
+		wg.Add(1)
+		go func(g *MotorGroup) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := g.Run([]TachoSpeed{100, 200}); err != nil {
+					t.Error("Run:", err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestNewMotorGroupLockOrder(t *testing.T) {
+	a, b, _ := newFakeMotorGroup(t)
+	g := NewMotorGroup(b, a)
+	if len(g.lockOrder) != 2 || g.lockOrder[0] != a || g.lockOrder[1] != b {
+		t.Error("lock order does not follow the order the motors were opened")
+	}
+	for _, motors := range [][]*TachoMotor{{a, nil}, {a, b, a}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewMotorGroup(%v) did not panic", motors)
+				}
+			}()
+			NewMotorGroup(motors...)
+		}()
+	}
+}
+
+func TestMotorGroupReconnect(t *testing.T) {
+	a, b, motorsDir := newFakeMotorGroup(t)
+	a.SetReconnectTimeout(10 * time.Second)
+	g := NewMotorGroup(a, b)
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fake files stay writable after they are removed, so simulate the
+	// first command failing.
+	calls := 0
+	errc := make(chan error, 1)
+	go func() {
+		errc <- g.do("test", func(i int, m *TachoMotor) (string, error) {
+			if m == a {
+				calls++
+				if calls == 1 {
+					return "", errRemoved
+				}
+			}
+			return m.prepareRun(100)
+		})
+	}()
+	waitForReconnecting(t, a)
+
+	// The rest of the group can be stopped while a reconnects.
+	stopped := make(chan error, 1)
+	go func() { stopped <- b.Stop(Brake) }()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Error("Stop:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked while another motor in the group was reconnecting")
+	}
+	if got, want := readFile(t, motorsDir, "motor1/command"), "stop"; got != want {
+		t.Errorf("motor1/command = %q; want %q", got, want)
+	}
+
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor2", "ev3-ports:outA", 0))
+	if err := <-errc; err != nil {
+		t.Fatal("do:", err)
+	}
+	for _, name := range []string{"motor1", "motor2"} {
+		if got, want := readFile(t, motorsDir, name+"/command"), "run-forever"; got != want {
+			t.Errorf("%s/command = %q; want %q", name, got, want)
+		}
+	}
+}
+
+func TestMotorGroupStopReconnecting(t *testing.T) {
+	a, b, motorsDir := newFakeMotorGroup(t)
+	a.SetReconnectTimeout(10 * time.Second)
+	g := NewMotorGroup(a, b)
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+	// Fake files stay writable after they are removed, so simulate a
+	// command on a failing.
+	errc := make(chan error, 1)
+	go func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		calls := 0
+		errc <- a.retry(func() error {
+			calls++
+			if calls == 1 {
+				return errRemoved
+			}
+			return nil
+		})
+	}()
+	waitForReconnecting(t, a)
+
+	tests := []struct {
+		name string
+		f    func() error
+		want string
+	}{
+		{"Stop", func() error { return g.Stop(Brake) }, "stop"},
+		{"Reset", g.Reset, "reset"},
+	}
+	for _, test := range tests {
+		done := make(chan error, 1)
+		go func() { done <- test.f() }()
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrDeviceRemoved) {
+				t.Errorf("%s() = %v; want %v", test.name, err, ErrDeviceRemoved)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s blocked while a motor in the group was reconnecting", test.name)
+		}
+		if got := readFile(t, motorsDir, "motor1/command"); got != test.want {
+			t.Errorf("after %s, motor1/command = %q; want %q", test.name, got, test.want)
+		}
+	}
+
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor2", "ev3-ports:outA", 0))
+	if err := <-errc; err != nil {
+		t.Error("retry:", err)
+	}
+}
+
+func TestMotorGroupStopContinuesAfterError(t *testing.T) {
+	a, b, motorsDir := newFakeMotorGroup(t)
+	a.stopMu.Lock()
+	a.stopActions[Hold] = false
+	a.stopMu.Unlock()
+	g := NewMotorGroup(a, b)
+
+	if err := g.Stop(Hold); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Stop(Hold) = %v; want %v", err, ErrUnsupported)
+	}
+	if got := readFile(t, motorsDir, "motor0/command"); got != "" {
+		t.Errorf("motor0/command = %q; want \"\"", got)
+	}
+	want := map[string]string{
+		"motor1/stop_action": "hold",
+		"motor1/command":     "stop",
+	}
+	for fname, want := range want {
+		if got := readFile(t, motorsDir, fname); got != want {
+			t.Errorf("%s = %q; want %q", fname, got, want)
+		}
+	}
+}
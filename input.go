@@ -0,0 +1,89 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"io"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inputEvent is the Linux evdev struct input_event. Its layout depends on
+// the size of struct timeval, so it is always read and written in the
+// machine's native layout.
+type inputEvent struct {
+	time  unix.Timeval
+	typ   uint16
+	code  uint16
+	value int32
+}
+
+// Event types and codes. See <linux/input-event-codes.h>.
+const (
+	evKey = 0x01
+	evSnd = 0x12
+
+	sndTone = 0x02
+
+	keyBackspace = 14
+	keyEnter     = 28
+	keyUp        = 103
+	keyLeft      = 105
+	keyRight     = 106
+	keyDown      = 108
+	keyMax       = 0x2ff
+)
+
+// eviocgkey is the EVIOCGKEY(len) ioctl request for a key bit array that
+// can hold every key code.
+var eviocgkey = ioc(iocRead, 'E', 0x18, (keyMax+7)/8)
+
+func (ev *inputEvent) timestamp() time.Time {
+	sec, nsec := ev.time.Unix()
+	return time.Unix(sec, nsec)
+}
+
+// readInputEvents reads as many whole events as will fit in buf.
+func readInputEvents(r io.Reader, buf []inputEvent) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	size := int(unsafe.Sizeof(buf[0]))
+	total := len(buf) * size
+	p := (*[1 << 20]byte)(unsafe.Pointer(&buf[0]))[:total:total]
+	n, err := io.ReadAtLeast(r, p, size)
+	if n%size != 0 && err == nil {
+		// evdev never returns partial events, but regular files might.
+		_, err = io.ReadFull(r, p[n:n+size-n%size])
+		n += size - n%size
+	}
+	return n / size, err
+}
+
+// writeInputEvent writes a single event stamped with the current time.
+func writeInputEvent(w io.Writer, typ, code uint16, value int32) error {
+	ev := inputEvent{
+		time:  unix.NsecToTimeval(time.Now().UnixNano()),
+		typ:   typ,
+		code:  code,
+		value: value,
+	}
+	_, err := w.Write((*[unsafe.Sizeof(ev)]byte)(unsafe.Pointer(&ev))[:])
+	return err
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Directions for ioctl request numbers. See <asm-generic/ioctl.h>.
+const (
+	iocNone  = 0
+	iocWrite = 1
+	iocRead  = 2
+)
+
+// ioc encodes an ioctl request number like the _IOC macro.
+func ioc(dir, typ, nr, size uintptr) uint {
+	return uint(dir<<30 | size<<16 | typ<<8 | nr)
+}
+
+// ioctl performs an ioctl on the file. Unlike using file.Fd(), it does not
+// put the file into blocking mode, so concurrent reads can still be
+// interrupted by Close.
+func ioctl(file *os.File, req uint, arg unsafe.Pointer) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = unix.Syscall(unix.SYS_IOCTL, fd, uintptr(req), uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ioctlInt performs an ioctl on the file that takes an integer argument.
+func ioctlInt(file *os.File, req uint, arg int) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = unix.Syscall(unix.SYS_IOCTL, fd, uintptr(req), uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names of the EV3 brick status LEDs.
+const (
+	LeftRedLED    = "led0:red:brick-status"
+	LeftGreenLED  = "led0:green:brick-status"
+	RightRedLED   = "led1:red:brick-status"
+	RightGreenLED = "led1:green:brick-status"
+)
+
+// An LED is a light controlled by the Linux LED class driver.
+type LED struct {
+	path          string
+	maxBrightness int
+	brightness    *os.File
+	trigger       *os.File
+}
+
+// OpenLED opens the LED with the given name, like "led0:red:brick-status".
+func (brick *Brick) OpenLED(name string) (*LED, error) {
+	led, err := newLED(filepath.Join(brick.root, "sys", "class", "leds", name))
+	if err != nil {
+		return nil, fmt.Errorf("open led %q: %w", name, err)
+	}
+	return led, nil
+}
+
+func newLED(path string) (_ *LED, err error) {
+	led := &LED{path: path}
+	maxBrightnessFile, err := os.Open(filepath.Join(path, "max_brightness"))
+	if err != nil {
+		return nil, err
+	}
+	maxBrightness, err := readAttrInt(maxBrightnessFile, 32)
+	maxBrightnessFile.Close()
+	if err != nil {
+		return nil, err
+	}
+	led.maxBrightness = int(maxBrightness)
+	if led.brightness, err = os.OpenFile(filepath.Join(path, "brightness"), os.O_RDWR, 0); err != nil {
+		return nil, err
+	}
+	if led.trigger, err = openAttrWrite(filepath.Join(path, "trigger")); err != nil {
+		led.brightness.Close()
+		return nil, err
+	}
+	return led, nil
+}
+
+// Close turns off the LED and cleans up its resources.
+func (led *LED) Close() error {
+	firstErr := led.setTrigger("none")
+	if err := writeAttrInt(led.brightness, 0); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if err := led.brightness.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if err := led.trigger.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr != nil {
+		return fmt.Errorf("close led: %w", firstErr)
+	}
+	return nil
+}
+
+// Name returns the LED's name, like "led0:red:brick-status".
+func (led *LED) Name() string {
+	return filepath.Base(led.path)
+}
+
+// MaxBrightness returns the maximum value accepted by SetBrightness.
+func (led *LED) MaxBrightness() int {
+	return led.maxBrightness
+}
+
+// Brightness reads the current brightness of the LED.
+func (led *LED) Brightness() (int, error) {
+	i, err := readAttrInt(led.brightness, 32)
+	if err != nil {
+		return 0, fmt.Errorf("read led brightness: %w", err)
+	}
+	return int(i), nil
+}
+
+// SetBrightness stops any blinking and sets the brightness of the LED.
+// It returns an error if b is not in the range [0, led.MaxBrightness()].
+func (led *LED) SetBrightness(b int) error {
+	if err := led.setTrigger("none"); err != nil {
+		return fmt.Errorf("set led brightness: %w", err)
+	}
+	if err := led.setBrightness(b); err != nil {
+		return fmt.Errorf("set led brightness: %w", err)
+	}
+	return nil
+}
+
+func (led *LED) setBrightness(b int) error {
+	if b < 0 || b > led.maxBrightness {
+		return fmt.Errorf("brightness %d out of range [0, %d]", b, led.maxBrightness)
+	}
+	return writeAttrInt(led.brightness, int64(b))
+}
+
+// Blink instructs the kernel to blink the LED, staying on for the on
+// duration and off for the off duration. Blinking continues without any
+// further involvement from the program until another brightness or blink
+// is set.
+func (led *LED) Blink(on, off time.Duration) error {
+	if on <= 0 || off <= 0 {
+		return fmt.Errorf("blink led: durations must be positive")
+	}
+	if err := led.setTrigger("timer"); err != nil {
+		return fmt.Errorf("blink led: %w", err)
+	}
+	// The timer trigger creates the delay attributes when it is activated.
+	for _, d := range []struct {
+		name string
+		t    time.Duration
+	}{{"delay_on", on}, {"delay_off", off}} {
+		f, err := openAttrWrite(filepath.Join(led.path, d.name))
+		if err != nil {
+			return fmt.Errorf("blink led: %w", err)
+		}
+		err = writeAttrInt(f, d.t.Milliseconds())
+		closeErr := f.Close()
+		if err != nil {
+			return fmt.Errorf("blink led: %w", err)
+		}
+		if closeErr != nil {
+			return fmt.Errorf("blink led: %w", closeErr)
+		}
+	}
+	return nil
+}
+
+func (led *LED) setTrigger(name string) error {
+	return writeAttr(led.trigger, []byte(name))
+}
+
+// LEDStep is a single step in a software LED pattern.
+type LEDStep struct {
+	// Brightness is the brightness of the LED for the step.
+	Brightness int
+	// Duration is how long the step lasts.
+	Duration time.Duration
+}
+
+// PlayPattern plays the steps in order, repeating the whole pattern the
+// given number of times. A negative repeat count repeats the pattern until
+// ctx is done. PlayPattern blocks until the pattern finishes or ctx is done,
+// and always turns the LED off before returning.
+func (led *LED) PlayPattern(ctx context.Context, pattern []LEDStep, repeat int) error {
+	for _, step := range pattern {
+		if step.Brightness < 0 || step.Brightness > led.maxBrightness {
+			return fmt.Errorf("play led pattern: brightness %d out of range [0, %d]", step.Brightness, led.maxBrightness)
+		}
+	}
+	if err := led.setTrigger("none"); err != nil {
+		return fmt.Errorf("play led pattern: %w", err)
+	}
+	err := led.playPattern(ctx, pattern, repeat)
+	if offErr := led.setBrightness(0); offErr != nil && err == nil {
+		err = offErr
+	}
+	if err != nil {
+		return fmt.Errorf("play led pattern: %w", err)
+	}
+	return nil
+}
+
+func (led *LED) playPattern(ctx context.Context, pattern []LEDStep, repeat int) error {
+	if len(pattern) == 0 {
+		return nil
+	}
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+	for i := 0; repeat < 0 || i < repeat; i++ {
+		for _, step := range pattern {
+			if err := led.setBrightness(step.Brightness); err != nil {
+				return err
+			}
+			timer.Reset(step.Duration)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// BlinkCode returns a pattern that flashes an LED n times at the given
+// brightness followed by a pause. It is intended for signaling numeric
+// codes, like errors, with PlayPattern.
+func BlinkCode(n int, brightness int, on, off, pause time.Duration) []LEDStep {
+	if n <= 0 {
+		return []LEDStep{{Brightness: 0, Duration: pause}}
+	}
+	pattern := make([]LEDStep, 0, 2*n)
+	for i := 0; i < n; i++ {
+		pattern = append(pattern,
+			LEDStep{Brightness: brightness, Duration: on},
+			LEDStep{Brightness: 0, Duration: off},
+		)
+	}
+	pattern[len(pattern)-1].Duration += pause
+	return pattern
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLED(t *testing.T) {
+	const ledDir = "sys/class/leds/" + LeftGreenLED
+	newFakeLED := func(t *testing.T) (*LED, string) {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{
+			ledDir + "/max_brightness": "255\n",
+			ledDir + "/brightness":     "0\n",
+			ledDir + "/trigger":        "[none] timer heartbeat\n",
+			ledDir + "/delay_on":       "500\n",
+			ledDir + "/delay_off":      "500\n",
+		})
+		led, err := newBrick(root).OpenLED(LeftGreenLED)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { led.Close() })
+		return led, root
+	}
+
+	t.Run("SetBrightness", func(t *testing.T) {
+		led, root := newFakeLED(t)
+		if got, want := led.MaxBrightness(), 255; got != want {
+			t.Errorf("MaxBrightness() = %d; want %d", got, want)
+		}
+		if err := led.SetBrightness(128); err != nil {
+			t.Error("SetBrightness(128):", err)
+		}
+		if got, err := led.Brightness(); got != 128 || err != nil {
+			t.Errorf("Brightness() = %d, %v; want 128, <nil>", got, err)
+		}
+		if got := readFile(t, root, ledDir+"/trigger"); got != "none" {
+			t.Errorf("trigger = %q; want \"none\"", got)
+		}
+		if err := led.SetBrightness(256); err == nil {
+			t.Error("SetBrightness(256) did not return an error")
+		}
+	})
+	t.Run("Blink", func(t *testing.T) {
+		led, root := newFakeLED(t)
+		if err := led.Blink(100*time.Millisecond, 300*time.Millisecond); err != nil {
+			t.Fatal("Blink:", err)
+		}
+		want := map[string]string{
+			"trigger":   "timer",
+			"delay_on":  "100",
+			"delay_off": "300",
+		}
+		for name, want := range want {
+			if got := readFile(t, root, ledDir+"/"+name); got != want {
+				t.Errorf("%s = %q; want %q", name, got, want)
+			}
+		}
+	})
+	t.Run("PlayPattern", func(t *testing.T) {
+		led, root := newFakeLED(t)
+		pattern := BlinkCode(3, 255, time.Millisecond, time.Millisecond, time.Millisecond)
+		if err := led.PlayPattern(context.Background(), pattern, 2); err != nil {
+			t.Error("PlayPattern:", err)
+		}
+		if got := readFile(t, root, ledDir+"/brightness"); got != "0" {
+			t.Errorf("brightness after pattern = %q; want \"0\"", got)
+		}
+	})
+	t.Run("PlayPatternCanceled", func(t *testing.T) {
+		led, _ := newFakeLED(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		pattern := []LEDStep{{Brightness: 255, Duration: time.Millisecond}}
+		if err := led.PlayPattern(ctx, pattern, -1); err == nil {
+			t.Error("PlayPattern with infinite repeat returned nil error")
+		}
+	})
+}
+
+func TestBlinkCode(t *testing.T) {
+	got := BlinkCode(2, 10, 1*time.Second, 2*time.Second, 5*time.Second)
+	want := []LEDStep{
+		{Brightness: 10, Duration: 1 * time.Second},
+		{Brightness: 0, Duration: 2 * time.Second},
+		{Brightness: 10, Duration: 1 * time.Second},
+		{Brightness: 0, Duration: 7 * time.Second},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("BlinkCode(2, ...) = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("BlinkCode(2, ...)[%d] = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TachoMotorLimits is a set of software safety limits for a motor. The
+// zero value imposes no limits beyond the motor's maximum speed.
+type TachoMotorLimits struct {
+	// MaxSpeed caps the speed of every command. Zero means the motor's
+	// MaxSpeed.
+	MaxSpeed TachoSpeed
+
+	// If LimitPosition is true, RunToPosition and RunToDelta will not move
+	// the motor outside of [MinPosition, MaxPosition]. Run and RunTimed
+	// cannot be checked against position limits: use MaxRunTime or a
+	// Watchdog to bound them.
+	LimitPosition bool
+	MinPosition   TachoPosition
+	MaxPosition   TachoPosition
+
+	// MaxRunTime bounds how long the motor runs for a single command. Zero
+	// means no limit. With a maximum run time, Run stops the motor after
+	// MaxRunTime unless another command is given first.
+	MaxRunTime time.Duration
+
+	// Policy determines what happens to commands that exceed the limits.
+	Policy LimitPolicy
+}
+
+// LimitPolicy determines how a TachoMotor handles commands that exceed its
+// limits.
+type LimitPolicy int
+
+// Limit policies.
+const (
+	// RejectOutOfLimits returns an error wrapping ErrLimitExceeded without
+	// changing the motor's behavior.
+	RejectOutOfLimits LimitPolicy = iota
+	// ClampToLimits runs the command with the speed, position or duration
+	// moved to the nearest value within the limits.
+	ClampToLimits
+)
+
+// String returns the name of the policy.
+func (policy LimitPolicy) String() string {
+	switch policy {
+	case RejectOutOfLimits:
+		return "reject"
+	case ClampToLimits:
+		return "clamp"
+	default:
+		return fmt.Sprintf("LimitPolicy(%d)", int(policy))
+	}
+}
+
+// ErrLimitExceeded is returned for commands that exceed a TachoMotor's
+// limits under the RejectOutOfLimits policy.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+func (limits *TachoMotorLimits) validate() error {
+	if limits.MaxSpeed < 0 {
+		return fmt.Errorf("negative max speed %d", limits.MaxSpeed)
+	}
+	if limits.LimitPosition && limits.MinPosition > limits.MaxPosition {
+		return fmt.Errorf("min position %d greater than max position %d", limits.MinPosition, limits.MaxPosition)
+	}
+	if limits.MaxRunTime < 0 {
+		return fmt.Errorf("negative max run time %v", limits.MaxRunTime)
+	}
+	if limits.Policy != RejectOutOfLimits && limits.Policy != ClampToLimits {
+		return fmt.Errorf("invalid policy %v", limits.Policy)
+	}
+	return nil
+}
+
+// SetLimits changes the motor's safety limits. The limits apply to commands
+// issued after SetLimits returns; a command that is already running is not
+// changed.
+func (m *TachoMotor) SetLimits(limits TachoMotorLimits) error {
+	if err := limits.validate(); err != nil {
+		return fmt.Errorf("set motor limits: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = limits
+	return nil
+}
+
+// Limits returns the motor's safety limits.
+func (m *TachoMotor) Limits() TachoMotorLimits {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.limits
+}
+
+// limitSpeed applies the limits to a speed. The caller must be holding
+// onto m.mu.
+func (m *TachoMotor) limitSpeed(speed TachoSpeed) (TachoSpeed, error) {
+	limit := m.limits.MaxSpeed
+	if limit == 0 || limit > m.maxSpeed {
+		return speed, nil
+	}
+	if speed <= limit && speed >= -limit {
+		return speed, nil
+	}
+	if m.limits.Policy != ClampToLimits {
+		return 0, fmt.Errorf("speed %d beyond limit %d: %w", speed, limit, ErrLimitExceeded)
+	}
+	if speed > limit {
+		return limit, nil
+	}
+	return -limit, nil
+}
+
+// limitPosition applies the limits to an absolute position. The caller must
+// be holding onto m.mu.
+func (m *TachoMotor) limitPosition(pos TachoPosition) (TachoPosition, error) {
+	if !m.limits.LimitPosition || (m.limits.MinPosition <= pos && pos <= m.limits.MaxPosition) {
+		return pos, nil
+	}
+	if m.limits.Policy != ClampToLimits {
+		return 0, fmt.Errorf("position %d outside limits [%d, %d]: %w", pos, m.limits.MinPosition, m.limits.MaxPosition, ErrLimitExceeded)
+	}
+	if pos < m.limits.MinPosition {
+		return m.limits.MinPosition, nil
+	}
+	return m.limits.MaxPosition, nil
+}
+
+// limitRunTime applies the limits to a run duration. The caller must be
+// holding onto m.mu.
+func (m *TachoMotor) limitRunTime(t time.Duration) (time.Duration, error) {
+	if m.limits.MaxRunTime == 0 || t <= m.limits.MaxRunTime {
+		return t, nil
+	}
+	if m.limits.Policy != ClampToLimits {
+		return 0, fmt.Errorf("run time %v beyond limit %v: %w", t, m.limits.MaxRunTime, ErrLimitExceeded)
+	}
+	return m.limits.MaxRunTime, nil
+}
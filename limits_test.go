@@ -0,0 +1,131 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTachoMotorLimits(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	setup := func(t *testing.T, policy LimitPolicy) (*TachoMotor, string) {
+		m, motorsDir := newFakeTachoMotor(t, addr)
+		writeFiles(t, motorsDir, map[string]string{"motor0/position": "100\n"})
+		err := m.SetLimits(TachoMotorLimits{
+			MaxSpeed:      500,
+			LimitPosition: true,
+			MinPosition:   -90,
+			MaxPosition:   180,
+			MaxRunTime:    2 * time.Second,
+			Policy:        policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m, filepath.Join(motorsDir, "motor0")
+	}
+
+	t.Run("Reject", func(t *testing.T) {
+		m, dir := setup(t, RejectOutOfLimits)
+		if err := m.Run(600); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Run(600) = %v; want %v", err, ErrLimitExceeded)
+		}
+		if err := m.RunToPosition(200, nil); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("RunToPosition(200) = %v; want %v", err, ErrLimitExceeded)
+		}
+		if err := m.RunToDelta(100, nil); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("RunToDelta(100) from 100 = %v; want %v", err, ErrLimitExceeded)
+		}
+		if err := m.RunTimed(3*time.Second, nil); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("RunTimed(3s) = %v; want %v", err, ErrLimitExceeded)
+		}
+		if got, want := readFile(t, dir, "command"), ""; got != want {
+			t.Errorf("command = %q; want %q", got, want)
+		}
+
+		if err := m.RunToDelta(-150, &TachoMotorParams{Speed: 400}); err != nil {
+			t.Errorf("RunToDelta(-150): %v", err)
+		}
+		if got, want := readFile(t, dir, "position_sp"), "-150"; got != want {
+			t.Errorf("position_sp = %q; want %q", got, want)
+		}
+	})
+
+	t.Run("Clamp", func(t *testing.T) {
+		m, dir := setup(t, ClampToLimits)
+		if err := m.Run(-600); err != nil {
+			t.Error("Run(-600):", err)
+		}
+		if got, want := readFile(t, dir, "speed_sp"), "-500"; got != want {
+			t.Errorf("speed_sp = %q; want %q", got, want)
+		}
+		if got, want := readFile(t, dir, "command"), "run-timed"; got != want {
+			t.Errorf("command = %q; want %q", got, want)
+		}
+		if got, want := readFile(t, dir, "time_sp"), "2000"; got != want {
+			t.Errorf("time_sp = %q; want %q", got, want)
+		}
+
+		if err := m.RunToPosition(200, nil); err != nil {
+			t.Error("RunToPosition(200):", err)
+		}
+		if got, want := readFile(t, dir, "position_sp"), "180"; got != want {
+			t.Errorf("position_sp = %q; want %q", got, want)
+		}
+		if err := m.RunToDelta(-300, nil); err != nil {
+			t.Error("RunToDelta(-300):", err)
+		}
+		if got, want := readFile(t, dir, "position_sp"), "-190"; got != want {
+			t.Errorf("position_sp = %q; want %q", got, want)
+		}
+		if err := m.RunTimed(3*time.Second, nil); err != nil {
+			t.Error("RunTimed(3s):", err)
+		}
+		if got, want := readFile(t, dir, "time_sp"), "2000"; got != want {
+			t.Errorf("time_sp = %q; want %q", got, want)
+		}
+	})
+
+	t.Run("LoweredAfterCommand", func(t *testing.T) {
+		m, dir := setup(t, ClampToLimits)
+		if err := m.RunToPosition(0, &TachoMotorParams{Speed: 400}); err != nil {
+			t.Fatal(err)
+		}
+		limits := m.Limits()
+		limits.MaxSpeed = 100
+		if err := m.SetLimits(limits); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.RunToPosition(10, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := readFile(t, dir, "speed_sp"), "100"; got != want {
+			t.Errorf("speed_sp = %q; want %q", got, want)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		m, _ := setup(t, RejectOutOfLimits)
+		err := m.SetLimits(TachoMotorLimits{LimitPosition: true, MinPosition: 10, MaxPosition: -10})
+		if err == nil {
+			t.Error("SetLimits with inverted position limits did not return an error")
+		}
+	})
+}
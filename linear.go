@@ -0,0 +1,76 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import "fmt"
+
+// CountPerMeter returns the number of tacho counts in one meter of travel of
+// a linear actuator. It returns zero for rotational motors.
+func (m *TachoMotor) CountPerMeter() TachoDelta {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.countPerMeter
+}
+
+// FullTravelCount returns the number of tacho counts in the full travel of a
+// linear actuator. It returns zero for rotational motors. Positions of a
+// linear actuator are usually between 0 and the full travel count, which
+// can be enforced with SetLimits.
+func (m *TachoMotor) FullTravelCount() TachoDelta {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fullTravelCount
+}
+
+// RunToDistance instructs a linear actuator to run until it reaches an
+// absolute position, given in meters from position zero, then stop. It
+// returns an error wrapping ErrUnsupported for rotational motors.
+func (m *TachoMotor) RunToDistance(meters float64, params *TachoMotorParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.retryCommand(func() (string, error) {
+		if m.countPerMeter == 0 {
+			return "", ErrUnsupported
+		}
+		pos := TachoPosition(Meters(float64(m.countPerMeter), meters))
+		return m.prepareRunToPosition(pos, params)
+	})
+	if err != nil {
+		return fmt.Errorf("run motor to distance %gm: %w", meters, err)
+	}
+	return nil
+}
+
+// RunForDistance instructs a linear actuator to run for a distance in
+// meters relative to the current position then stop. Negative distances
+// retract the actuator. It returns an error wrapping ErrUnsupported for
+// rotational motors.
+func (m *TachoMotor) RunForDistance(meters float64, params *TachoMotorParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.retryCommand(func() (string, error) {
+		if m.countPerMeter == 0 {
+			return "", ErrUnsupported
+		}
+		delta := Meters(float64(m.countPerMeter), meters)
+		return m.prepareRunToDelta(delta, params)
+	})
+	if err != nil {
+		return fmt.Errorf("run motor for distance %gm: %w", meters, err)
+	}
+	return nil
+}
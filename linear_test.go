@@ -0,0 +1,97 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// fakeLinearActuatorFiles returns the files for a fake linear actuator
+// with 2000 counts per meter and 200 counts of travel.
+func fakeLinearActuatorFiles(dir, addr string) map[string]string {
+	files := fakeTachoMotorFiles(dir, addr, 0)
+	delete(files, dir+"/count_per_rot")
+	files[dir+"/count_per_m"] = "2000\n"
+	files[dir+"/full_travel_count"] = "200\n"
+	return files
+}
+
+func TestLinearActuator(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	root := t.TempDir()
+	motorsDir := filepath.Join(root, "sys", "class", "tacho-motor")
+	writeFiles(t, motorsDir, fakeLinearActuatorFiles("motor0", addr))
+	m := openFakeTachoMotor(t, newBrick(root), addr)
+
+	if got, want := m.CountPerMeter(), TachoDelta(2000); got != want {
+		t.Errorf("CountPerMeter() = %d; want %d", got, want)
+	}
+	if got, want := m.FullTravelCount(), TachoDelta(200); got != want {
+		t.Errorf("FullTravelCount() = %d; want %d", got, want)
+	}
+	if got := m.CountPerRotation(); got != 0 {
+		t.Errorf("CountPerRotation() = %d; want 0", got)
+	}
+
+	if err := m.RunToDistance(0.05, nil); err != nil {
+		t.Fatal("RunToDistance(0.05, nil):", err)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/position_sp"), "100"; got != want {
+		t.Errorf("after RunToDistance(0.05, nil), position_sp = %q; want %q", got, want)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "run-to-abs-pos"; got != want {
+		t.Errorf("after RunToDistance(0.05, nil), command = %q; want %q", got, want)
+	}
+
+	if err := m.RunForDistance(-0.01, nil); err != nil {
+		t.Fatal("RunForDistance(-0.01, nil):", err)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/position_sp"), "-20"; got != want {
+		t.Errorf("after RunForDistance(-0.01, nil), position_sp = %q; want %q", got, want)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "run-to-rel-pos"; got != want {
+		t.Errorf("after RunForDistance(-0.01, nil), command = %q; want %q", got, want)
+	}
+}
+
+func TestLinearActuatorRotationalMotor(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, _ := newFakeTachoMotor(t, addr)
+
+	if got := m.CountPerMeter(); got != 0 {
+		t.Errorf("CountPerMeter() = %d; want 0", got)
+	}
+	if err := m.RunToDistance(0.05, nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("RunToDistance(0.05, nil) = %v; want %v", err, ErrUnsupported)
+	}
+	if err := m.RunForDistance(0.05, nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("RunForDistance(0.05, nil) = %v; want %v", err, ErrUnsupported)
+	}
+}
+
+func TestNewTachoMotorMissingCounts(t *testing.T) {
+	dir := t.TempDir()
+	files := fakeTachoMotorFiles("motor0", "ev3-ports:outA", 0)
+	delete(files, "motor0/count_per_rot")
+	writeFiles(t, dir, files)
+	if m, err := newTachoMotor(filepath.Join(dir, "motor0")); err == nil {
+		m.closeFiles()
+		t.Error("newTachoMotor did not return an error")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Pose is the position and orientation of a robot on the floor, relative
+// to where tracking started. The robot starts at the origin facing along
+// the positive X axis.
+type Pose struct {
+	// X and Y are in meters. Positive Y is to the robot's starting left.
+	X, Y float64
+	// Heading is in degrees, increasing counter-clockwise. It is not
+	// wrapped, so after two full turns to the left it is 720.
+	Heading float64
+}
+
+// GyroFusion configures an Odometry to combine a gyro sensor's reading with
+// the wheels' estimate of heading. Wheels slip during fast turns, so a gyro
+// usually gives a better heading, while the wheels remain the only source
+// of distance traveled.
+type GyroFusion struct {
+	// Sensor's first value must be an angle in degrees, as with the EV3 gyro
+	// sensor in GYRO-ANG mode.
+	Sensor *Sensor
+	// Clockwise is true if the sensor's angle increases when the robot turns
+	// clockwise, as with an upright EV3 gyro sensor.
+	Clockwise bool
+	// Weight is how much of each change in heading comes from the gyro, in
+	// [0, 1]. One uses the gyro alone and zero ignores it.
+	Weight float64
+}
+
+// An Odometry estimates the pose of a DifferentialDrive by integrating the
+// motion of its wheels. It is safe to use from multiple goroutines.
+type Odometry struct {
+	drive *DifferentialDrive
+
+	mu          sync.Mutex
+	gyro        *GyroFusion
+	pose        Pose
+	heading     float64 // radians
+	initialized bool
+	lastLeft    TachoPosition
+	lastRight   TachoPosition
+	lastGyro    float64
+}
+
+// NewOdometry returns an odometry tracker for the drive. The first call to
+// Update records the starting encoder positions.
+func NewOdometry(d *DifferentialDrive) *Odometry {
+	return &Odometry{drive: d}
+}
+
+// SetGyro starts fusing a gyro's heading with the wheels'. A nil g stops
+// using the gyro.
+func (o *Odometry) SetGyro(g *GyroFusion) error {
+	if g != nil {
+		if g.Sensor == nil {
+			return fmt.Errorf("set odometry gyro: nil sensor")
+		}
+		if !(0 <= g.Weight && g.Weight <= 1) {
+			return fmt.Errorf("set odometry gyro: weight %g out of range [0, 1]", g.Weight)
+		}
+		g = &GyroFusion{Sensor: g.Sensor, Clockwise: g.Clockwise, Weight: g.Weight}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.gyro = g
+	// Take a new gyro baseline on the next update.
+	o.initialized = false
+	return nil
+}
+
+// Pose returns the most recent pose estimate.
+func (o *Odometry) Pose() Pose {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pose
+}
+
+// Reset sets the current pose estimate. The next call to Update records
+// new starting encoder positions.
+func (o *Odometry) Reset(pose Pose) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pose = pose
+	o.heading = degreesToRadians(pose.Heading)
+	o.initialized = false
+}
+
+// Update reads the encoders (and gyro, if set) and advances the pose
+// estimate.
+func (o *Odometry) Update() (Pose, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	left, right := o.drive.Motors()
+	leftPos, err := left.Position()
+	if err != nil {
+		return o.pose, fmt.Errorf("update odometry: left motor: %w", err)
+	}
+	rightPos, err := right.Position()
+	if err != nil {
+		return o.pose, fmt.Errorf("update odometry: right motor: %w", err)
+	}
+	var gyro float64
+	if o.gyro != nil {
+		v, err := o.gyro.Sensor.Value(0)
+		if err != nil {
+			return o.pose, fmt.Errorf("update odometry: gyro: %w", err)
+		}
+		gyro = degreesToRadians(v.Float64())
+		if o.gyro.Clockwise {
+			gyro = -gyro
+		}
+	}
+	o.integrate(leftPos, rightPos, gyro)
+	return o.pose, nil
+}
+
+// integrate advances the pose estimate given new encoder positions and a
+// counter-clockwise gyro angle in radians. The caller must be holding onto
+// o.mu.
+func (o *Odometry) integrate(leftPos, rightPos TachoPosition, gyro float64) {
+	if !o.initialized {
+		o.lastLeft, o.lastRight, o.lastGyro = leftPos, rightPos, gyro
+		o.initialized = true
+		return
+	}
+	left, right := o.drive.Motors()
+	dl := leftPos.Sub(o.lastLeft).Meters(o.drive.countsPerMeter(left))
+	dr := rightPos.Sub(o.lastRight).Meters(o.drive.countsPerMeter(right))
+	o.lastLeft, o.lastRight = leftPos, rightPos
+
+	ds := (dl + dr) / 2
+	dTheta := (dr - dl) / o.drive.trackWidth
+	if o.gyro != nil {
+		w := o.gyro.Weight
+		dTheta = (1-w)*dTheta + w*(gyro-o.lastGyro)
+		o.lastGyro = gyro
+	}
+
+	// Assume the robot moved along a straight line at the average of the
+	// old and new headings.
+	mid := o.heading + dTheta/2
+	o.pose.X += ds * math.Cos(mid)
+	o.pose.Y += ds * math.Sin(mid)
+	o.heading += dTheta
+	o.pose.Heading = o.heading * 180 / math.Pi
+}
+
+// Run calls Update at the given interval until ctx is done or an update
+// fails. Shorter intervals give more accurate estimates for curved paths.
+// An interval of zero or less means 20ms.
+func (o *Odometry) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = 20 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := o.Update(); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func newFakeOdometry(t *testing.T) (*Odometry, func(left, right int)) {
+	left, right, motorsDir := newFakeMotorGroup(t)
+	// 360 counts per meter.
+	d, err := NewDifferentialDrive(left, right, 1/math.Pi, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setPositions := func(l, r int) {
+		writeFiles(t, motorsDir, map[string]string{
+			"motor0/position": fmt.Sprintf("%d\n", l),
+			"motor1/position": fmt.Sprintf("%d\n", r),
+		})
+	}
+	return NewOdometry(d), setPositions
+}
+
+func checkPose(t *testing.T, got, want Pose) {
+	t.Helper()
+	const eps = 1e-9
+	if math.Abs(got.X-want.X) > eps || math.Abs(got.Y-want.Y) > eps || math.Abs(got.Heading-want.Heading) > eps {
+		t.Errorf("pose = %+v; want %+v", got, want)
+	}
+}
+
+func TestOdometry(t *testing.T) {
+	o, setPositions := newFakeOdometry(t)
+	setPositions(100, -50)
+	if _, err := o.Update(); err != nil {
+		t.Fatal(err)
+	}
+	checkPose(t, o.Pose(), Pose{})
+
+	// Straight ahead 1 meter.
+	setPositions(460, 310)
+	if _, err := o.Update(); err != nil {
+		t.Fatal(err)
+	}
+	checkPose(t, o.Pose(), Pose{X: 1})
+
+	// Turn in place by 1 radian to the left.
+	setPositions(370, 400)
+	if _, err := o.Update(); err != nil {
+		t.Fatal(err)
+	}
+	checkPose(t, o.Pose(), Pose{X: 1, Heading: 180 / math.Pi})
+
+	// Straight ahead 1 meter in the new direction.
+	setPositions(730, 760)
+	got, err := o.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPose(t, got, Pose{X: 1 + math.Cos(1), Y: math.Sin(1), Heading: 180 / math.Pi})
+
+	o.Reset(Pose{X: 5, Heading: 90})
+	if _, err := o.Update(); err != nil {
+		t.Fatal(err)
+	}
+	setPositions(1090, 1120)
+	if _, err := o.Update(); err != nil {
+		t.Fatal(err)
+	}
+	checkPose(t, o.Pose(), Pose{X: 5, Y: 1, Heading: 90})
+}
+
+func TestOdometryGyro(t *testing.T) {
+	o, setPositions := newFakeOdometry(t)
+	sensorDir := t.TempDir()
+	writeFiles(t, sensorDir, map[string]string{
+		"decimals":   "0\n",
+		"num_values": "1\n",
+		"value0":     "0\n",
+	})
+	gyro, err := newSensor(sensorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gyro.Close()
+	err = o.SetGyro(&GyroFusion{Sensor: gyro, Clockwise: true, Weight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setPositions(0, 0)
+	if _, err := o.Update(); err != nil {
+		t.Fatal(err)
+	}
+	// The wheels report driving straight, but the gyro reports a 90 degree
+	// turn to the left.
+	setPositions(360, 360)
+	writeFiles(t, sensorDir, map[string]string{"value0": "-90\n"})
+	if _, err := o.Update(); err != nil {
+		t.Fatal(err)
+	}
+	checkPose(t, o.Pose(), Pose{X: math.Sqrt(0.5), Y: math.Sqrt(0.5), Heading: 90})
+
+	if err := o.SetGyro(&GyroFusion{Sensor: gyro, Weight: 2}); err == nil {
+		t.Error("SetGyro with weight 2 did not return an error")
+	}
+}
+
+func TestOdometryRunZeroInterval(t *testing.T) {
+	o, setPositions := newFakeOdometry(t)
+	setPositions(0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := o.Run(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run(ctx, 0) = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// pcmConfig is the stream format of a PCM device.
+type pcmConfig struct {
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+}
+
+// pcmDevice is an open PCM playback stream. Writes must be whole frames.
+type pcmDevice interface {
+	io.Writer
+	// drain blocks until all written frames have been played.
+	drain() error
+	// drop discards any frames that have not been played yet.
+	drop() error
+	Close() error
+}
+
+// volumeControl is a mixer volume setting.
+type volumeControl interface {
+	// volume returns the volume as a percentage.
+	volume() (int, error)
+	// setVolume sets the volume as a percentage.
+	setVolume(percent int) error
+	Close() error
+}
+
+// ALSA PCM constants. See <sound/asound.h>.
+const (
+	sndrvPCMAccessRWInterleaved = 3
+
+	sndrvPCMFormatU8     = 1
+	sndrvPCMFormatS16LE  = 2
+	sndrvPCMFormatS32LE  = 10
+	sndrvPCMFormatS24LE3 = 32
+
+	sndrvPCMHWParamAccess     = 0
+	sndrvPCMHWParamFormat     = 1
+	sndrvPCMHWParamSubformat  = 2
+	sndrvPCMHWParamSampleBits = 8
+	sndrvPCMHWParamFrameBits  = 9
+	sndrvPCMHWParamChannels   = 10
+	sndrvPCMHWParamRate       = 11
+	sndrvPCMHWParamPeriodTime = 12
+
+	sndrvPCMHWParamFirstInterval = sndrvPCMHWParamSampleBits
+
+	sndIntervalInteger = 1 << 2
+)
+
+type sndMask struct {
+	bits [8]uint32
+}
+
+type sndInterval struct {
+	min, max uint32
+	flags    uint32
+}
+
+// sndPCMHWParams is struct snd_pcm_hw_params. Go's int and uint have the
+// same size as C's long on Linux, which keeps the layout correct on both
+// 32-bit and 64-bit machines.
+type sndPCMHWParams struct {
+	flags     uint32
+	masks     [3]sndMask
+	mres      [5]sndMask
+	intervals [12]sndInterval
+	ires      [9]sndInterval
+	rmask     uint32
+	cmask     uint32
+	info      uint32
+	msbits    uint32
+	rateNum   uint32
+	rateDen   uint32
+	fifoSize  uint
+	reserved  [64]byte
+}
+
+var (
+	sndrvPCMIoctlHWParams = ioc(iocRead|iocWrite, 'A', 0x11, unsafe.Sizeof(sndPCMHWParams{}))
+	sndrvPCMIoctlPrepare  = ioc(iocNone, 'A', 0x40, 0)
+	sndrvPCMIoctlDrop     = ioc(iocNone, 'A', 0x43, 0)
+	sndrvPCMIoctlDrain    = ioc(iocNone, 'A', 0x44, 0)
+)
+
+// newSndPCMHWParams returns hardware parameters that allow any
+// configuration, like snd_pcm_hw_params_any.
+func newSndPCMHWParams() *sndPCMHWParams {
+	p := new(sndPCMHWParams)
+	for i := range p.masks {
+		for j := range p.masks[i].bits {
+			p.masks[i].bits[j] = ^uint32(0)
+		}
+	}
+	for i := range p.intervals {
+		p.intervals[i].max = ^uint32(0)
+	}
+	p.rmask = ^uint32(0)
+	p.info = ^uint32(0)
+	return p
+}
+
+func (p *sndPCMHWParams) setMask(param int, value uint32) {
+	m := &p.masks[param]
+	for i := range m.bits {
+		m.bits[i] = 0
+	}
+	m.bits[value/32] = 1 << (value % 32)
+}
+
+func (p *sndPCMHWParams) setInterval(param int, value uint32) {
+	iv := &p.intervals[param-sndrvPCMHWParamFirstInterval]
+	iv.min = value
+	iv.max = value
+	iv.flags = sndIntervalInteger
+}
+
+func (p *sndPCMHWParams) setIntervalMin(param int, value uint32) {
+	p.intervals[param-sndrvPCMHWParamFirstInterval].min = value
+}
+
+// alsaPCM is a PCM playback device using the ALSA kernel interface.
+type alsaPCM struct {
+	file      *os.File
+	frameSize int
+}
+
+func openALSAPCM(path string, cfg pcmConfig) (*alsaPCM, error) {
+	var format uint32
+	switch cfg.bitsPerSample {
+	case 8:
+		format = sndrvPCMFormatU8
+	case 16:
+		format = sndrvPCMFormatS16LE
+	case 24:
+		format = sndrvPCMFormatS24LE3
+	case 32:
+		format = sndrvPCMFormatS32LE
+	default:
+		return nil, fmt.Errorf("open pcm: unsupported sample size of %d bits", cfg.bitsPerSample)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open pcm: %w", err)
+	}
+	params := newSndPCMHWParams()
+	params.setMask(sndrvPCMHWParamAccess, sndrvPCMAccessRWInterleaved)
+	params.setMask(sndrvPCMHWParamFormat, format)
+	params.setMask(sndrvPCMHWParamSubformat, 0)
+	params.setInterval(sndrvPCMHWParamSampleBits, uint32(cfg.bitsPerSample))
+	params.setInterval(sndrvPCMHWParamFrameBits, uint32(cfg.bitsPerSample*cfg.channels))
+	params.setInterval(sndrvPCMHWParamChannels, uint32(cfg.channels))
+	params.setInterval(sndrvPCMHWParamRate, uint32(cfg.sampleRate))
+	// Avoid tiny periods, which keep the EV3's CPU busy with interrupts.
+	params.setIntervalMin(sndrvPCMHWParamPeriodTime, 20000)
+	if err := ioctl(f, sndrvPCMIoctlHWParams, unsafe.Pointer(params)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open pcm: set %d channel %d Hz %d-bit format: %w", cfg.channels, cfg.sampleRate, cfg.bitsPerSample, err)
+	}
+	if err := ioctl(f, sndrvPCMIoctlPrepare, nil); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open pcm: %w", err)
+	}
+	return &alsaPCM{
+		file:      f,
+		frameSize: cfg.channels * ((cfg.bitsPerSample + 7) / 8),
+	}, nil
+}
+
+func (pcm *alsaPCM) Write(p []byte) (int, error) {
+	if len(p)%pcm.frameSize != 0 {
+		return 0, errors.New("write pcm: partial frame")
+	}
+	n := 0
+	for n < len(p) {
+		nn, err := pcm.file.Write(p[n:])
+		n += nn
+		if errors.Is(err, unix.EPIPE) {
+			// Underrun. Prepare the stream again and continue writing.
+			if err := ioctl(pcm.file, sndrvPCMIoctlPrepare, nil); err != nil {
+				return n, fmt.Errorf("write pcm: recover from underrun: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("write pcm: %w", err)
+		}
+	}
+	return n, nil
+}
+
+func (pcm *alsaPCM) drain() error {
+	// The Go runtime puts the file into non-blocking mode, where the kernel
+	// refuses to drain with EAGAIN. Block just for the duration of the drain.
+	conn, err := pcm.file.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("drain pcm: %w", err)
+	}
+	var drainErr error
+	err = conn.Control(func(fd uintptr) {
+		if drainErr = unix.SetNonblock(int(fd), false); drainErr != nil {
+			return
+		}
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, uintptr(sndrvPCMIoctlDrain), 0); errno != 0 {
+			drainErr = errno
+		}
+		unix.SetNonblock(int(fd), true)
+	})
+	if err != nil {
+		return fmt.Errorf("drain pcm: %w", err)
+	}
+	if drainErr != nil {
+		return fmt.Errorf("drain pcm: %w", drainErr)
+	}
+	return nil
+}
+
+func (pcm *alsaPCM) drop() error {
+	if err := ioctl(pcm.file, sndrvPCMIoctlDrop, nil); err != nil {
+		return fmt.Errorf("drop pcm: %w", err)
+	}
+	return nil
+}
+
+func (pcm *alsaPCM) Close() error {
+	return pcm.file.Close()
+}
+
+// ALSA control constants. See <sound/asound.h>.
+const (
+	sndrvCtlElemIfaceMixer = 2
+	sndrvCtlElemTypeInt    = 2
+)
+
+type sndCtlElemID struct {
+	numid     uint32
+	iface     int32
+	device    uint32
+	subdevice uint32
+	name      [44]byte
+	index     uint32
+}
+
+// sndCtlElemInfo is struct snd_ctl_elem_info.
+type sndCtlElemInfo struct {
+	id    sndCtlElemID
+	typ   int32
+	acc   uint32
+	count uint32
+	owner int32
+	// value is a union whose integer member is {long min, max, step}.
+	value    [128]byte
+	reserved [64]byte
+}
+
+// sndCtlElemValue is struct snd_ctl_elem_value. The value union is
+// 8-byte aligned on all architectures because it contains a long long.
+type sndCtlElemValue struct {
+	id       sndCtlElemID
+	indirect uint32
+	_        [4]byte
+	value    [128]int
+	reserved [128]byte
+}
+
+var (
+	sndrvCtlIoctlElemInfo  = ioc(iocRead|iocWrite, 'U', 0x11, unsafe.Sizeof(sndCtlElemInfo{}))
+	sndrvCtlIoctlElemRead  = ioc(iocRead|iocWrite, 'U', 0x12, unsafe.Sizeof(sndCtlElemValue{}))
+	sndrvCtlIoctlElemWrite = ioc(iocRead|iocWrite, 'U', 0x13, unsafe.Sizeof(sndCtlElemValue{}))
+)
+
+// alsaVolume is an integer mixer control, like "PCM Playback Volume".
+type alsaVolume struct {
+	file     *os.File
+	id       sndCtlElemID
+	min, max int
+	count    int
+}
+
+func openALSAVolume(path string, name string) (*alsaVolume, error) {
+	v := new(alsaVolume)
+	v.id.iface = sndrvCtlElemIfaceMixer
+	if len(name) >= len(v.id.name) {
+		return nil, fmt.Errorf("open mixer control %q: name too long", name)
+	}
+	copy(v.id.name[:], name)
+	var err error
+	v.file, err = os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open mixer control %q: %w", name, err)
+	}
+	info := &sndCtlElemInfo{id: v.id}
+	if err := ioctl(v.file, sndrvCtlIoctlElemInfo, unsafe.Pointer(info)); err != nil {
+		v.file.Close()
+		return nil, fmt.Errorf("open mixer control %q: %w", name, err)
+	}
+	if info.typ != sndrvCtlElemTypeInt {
+		v.file.Close()
+		return nil, fmt.Errorf("open mixer control %q: not an integer control", name)
+	}
+	limits := (*[3]int)(unsafe.Pointer(&info.value))
+	v.min, v.max = limits[0], limits[1]
+	v.count = int(info.count)
+	if v.count < 1 || v.count > len(sndCtlElemValue{}.value) || v.max <= v.min {
+		v.file.Close()
+		return nil, fmt.Errorf("open mixer control %q: unexpected control shape", name)
+	}
+	return v, nil
+}
+
+func (v *alsaVolume) volume() (int, error) {
+	val := &sndCtlElemValue{id: v.id}
+	if err := ioctl(v.file, sndrvCtlIoctlElemRead, unsafe.Pointer(val)); err != nil {
+		return 0, fmt.Errorf("read volume: %w", err)
+	}
+	return volumeToPercent(val.value[0], v.min, v.max), nil
+}
+
+func (v *alsaVolume) setVolume(percent int) error {
+	val := &sndCtlElemValue{id: v.id}
+	raw := volumeFromPercent(percent, v.min, v.max)
+	for i := 0; i < v.count; i++ {
+		val.value[i] = raw
+	}
+	if err := ioctl(v.file, sndrvCtlIoctlElemWrite, unsafe.Pointer(val)); err != nil {
+		return fmt.Errorf("set volume: %w", err)
+	}
+	return nil
+}
+
+func (v *alsaVolume) Close() error {
+	return v.file.Close()
+}
+
+// volumeFromPercent maps a percentage onto a control's range.
+func volumeFromPercent(percent, min, max int) int {
+	return min + (percent*(max-min)+50)/100
+}
+
+// volumeToPercent maps a control value onto a percentage.
+func volumeToPercent(raw, min, max int) int {
+	return ((raw-min)*100 + (max-min)/2) / (max - min)
+}
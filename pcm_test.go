@@ -0,0 +1,129 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unsafe"
+)
+
+func TestALSAStructSizes(t *testing.T) {
+	// Sizes from sizeof in C on 32-bit ARM (the EV3) and x86_64.
+	is64Bit := unsafe.Sizeof(uintptr(0)) == 8
+	tests := []struct {
+		name   string
+		got    uintptr
+		want32 uintptr
+		want64 uintptr
+	}{
+		{"snd_pcm_hw_params", unsafe.Sizeof(sndPCMHWParams{}), 604, 608},
+		{"snd_ctl_elem_info", unsafe.Sizeof(sndCtlElemInfo{}), 272, 272},
+		{"snd_ctl_elem_value", unsafe.Sizeof(sndCtlElemValue{}), 712, 1224},
+	}
+	for _, test := range tests {
+		want := test.want32
+		if is64Bit {
+			want = test.want64
+		}
+		if test.got != want {
+			t.Errorf("sizeof(struct %s) = %d; want %d", test.name, test.got, want)
+		}
+	}
+}
+
+func TestVolumePercent(t *testing.T) {
+	tests := []struct {
+		percent  int
+		min, max int
+		raw      int
+	}{
+		{0, 0, 256, 0},
+		{100, 0, 256, 256},
+		{50, 0, 256, 128},
+		{50, -100, 100, 0},
+		{30, 0, 10, 3},
+	}
+	for _, test := range tests {
+		if got := volumeFromPercent(test.percent, test.min, test.max); got != test.raw {
+			t.Errorf("volumeFromPercent(%d, %d, %d) = %d; want %d", test.percent, test.min, test.max, got, test.raw)
+		}
+		if got := volumeToPercent(test.raw, test.min, test.max); got != test.percent {
+			t.Errorf("volumeToPercent(%d, %d, %d) = %d; want %d", test.raw, test.min, test.max, got, test.percent)
+		}
+	}
+}
+
+func TestReadWAVHeader(t *testing.T) {
+	t.Run("PCM", func(t *testing.T) {
+		data := []byte{1, 2, 3, 4}
+		r := bytes.NewReader(makeWAV(1, 8000, 16, data))
+		hdr, err := readWAVHeader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := wavHeader{channels: 1, sampleRate: 8000, bitsPerSample: 16, dataSize: 4}
+		if *hdr != want {
+			t.Errorf("readWAVHeader(...) = %+v; want %+v", *hdr, want)
+		}
+		if r.Len() != len(data) {
+			t.Errorf("%d bytes left after header; want %d", r.Len(), len(data))
+		}
+	})
+	t.Run("NotWAV", func(t *testing.T) {
+		r := bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI LIST"))
+		if _, err := readWAVHeader(r); err == nil {
+			t.Error("readWAVHeader did not return an error")
+		}
+	})
+	t.Run("Compressed", func(t *testing.T) {
+		wav := makeWAV(1, 8000, 16, nil)
+		// Change the format tag to MP3.
+		binary.LittleEndian.PutUint16(wav[20:], 0x0055)
+		if _, err := readWAVHeader(bytes.NewReader(wav)); err == nil {
+			t.Error("readWAVHeader did not return an error")
+		}
+	})
+}
+
+// makeWAV returns a WAV file with a LIST chunk before the sample data.
+func makeWAV(channels, sampleRate, bitsPerSample int, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	le := binary.LittleEndian
+	buf.WriteString("RIFF")
+	binary.Write(buf, le, uint32(0))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(buf, le, uint32(16))
+	frameSize := channels * bitsPerSample / 8
+	binary.Write(buf, le, uint16(wavFormatPCM))
+	binary.Write(buf, le, uint16(channels))
+	binary.Write(buf, le, uint32(sampleRate))
+	binary.Write(buf, le, uint32(sampleRate*frameSize))
+	binary.Write(buf, le, uint16(frameSize))
+	binary.Write(buf, le, uint16(bitsPerSample))
+	buf.WriteString("LIST")
+	binary.Write(buf, le, uint32(3))
+	buf.WriteString("abc\x00")
+	buf.WriteString("data")
+	binary.Write(buf, le, uint32(len(data)))
+	buf.Write(data)
+	wav := buf.Bytes()
+	le.PutUint32(wav[4:], uint32(len(wav)-8))
+	return wav
+}
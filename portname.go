@@ -0,0 +1,127 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PortName is a platform-independent name for a port, as labeled on the
+// brick. The zero value is not a valid port name.
+type PortName int8
+
+// Port names.
+const (
+	InputPort1 PortName = 1 + iota
+	InputPort2
+	InputPort3
+	InputPort4
+	OutputPortA
+	OutputPortB
+	OutputPortC
+	OutputPortD
+)
+
+// ParsePortName parses a port name like "in1" or "outA". The "in" and "out"
+// prefixes are optional, so "1" and "A" are also accepted, as are the
+// BrickPi and BrickPi3 labels "S1" and "MA". Letters are matched
+// case-insensitively.
+func ParsePortName(s string) (PortName, error) {
+	t := strings.ToLower(s)
+	input, output := true, true
+	for _, prefix := range []string{"in", "s"} {
+		if strings.HasPrefix(t, prefix) {
+			t = t[len(prefix):]
+			output = false
+			break
+		}
+	}
+	for _, prefix := range []string{"out", "m"} {
+		if output && strings.HasPrefix(t, prefix) {
+			t = t[len(prefix):]
+			input = false
+			break
+		}
+	}
+	if len(t) == 1 {
+		switch c := t[0]; {
+		case input && '1' <= c && c <= '4':
+			return InputPort1 + PortName(c-'1'), nil
+		case output && 'a' <= c && c <= 'd':
+			return OutputPortA + PortName(c-'a'), nil
+		}
+	}
+	return 0, fmt.Errorf("parse port name %q: unknown port", s)
+}
+
+// IsInput reports whether name is one of the sensor ports.
+func (name PortName) IsInput() bool {
+	return InputPort1 <= name && name <= InputPort4
+}
+
+// IsOutput reports whether name is one of the motor ports.
+func (name PortName) IsOutput() bool {
+	return OutputPortA <= name && name <= OutputPortD
+}
+
+// String returns the name of the port, like "in1" or "outA".
+func (name PortName) String() string {
+	switch {
+	case name.IsInput():
+		return fmt.Sprintf("in%d", int(name-InputPort1)+1)
+	case name.IsOutput():
+		return "out" + string(rune('A'+int(name-OutputPortA)))
+	default:
+		return fmt.Sprintf("PortName(%d)", int(name))
+	}
+}
+
+// PortAddress returns the sysfs address of the named port on the platform,
+// like "spi0.1:S3" for InputPort3 on a BrickPi3.
+func (p Platform) PortAddress(name PortName) (string, error) {
+	var addrs []string
+	var i int
+	switch {
+	case name.IsInput():
+		addrs = p.InputPorts()
+		i = int(name - InputPort1)
+	case name.IsOutput():
+		addrs = p.OutputPorts()
+		i = int(name - OutputPortA)
+	default:
+		return "", fmt.Errorf("address of %v on %v: invalid port name", name, p)
+	}
+	if i >= len(addrs) {
+		return "", fmt.Errorf("address of %v on %v: unknown port layout", name, p)
+	}
+	return addrs[i], nil
+}
+
+// PortByName detects the platform and searches for the named port, like
+// PortByAddress. Subsequent calls for the same port will return an error.
+func (brick *Brick) PortByName(name PortName) (*Port, error) {
+	p, err := brick.Platform()
+	if err != nil {
+		return nil, fmt.Errorf("find port %v: %w", name, err)
+	}
+	addr, err := p.PortAddress(name)
+	if err != nil {
+		return nil, fmt.Errorf("find port %v: %w", name, err)
+	}
+	return brick.PortByAddress(addr)
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"testing"
+)
+
+func TestParsePortName(t *testing.T) {
+	tests := []struct {
+		s    string
+		want PortName
+		err  bool
+	}{
+		{s: "in1", want: InputPort1},
+		{s: "IN4", want: InputPort4},
+		{s: "2", want: InputPort2},
+		{s: "S3", want: InputPort3},
+		{s: "outA", want: OutputPortA},
+		{s: "outd", want: OutputPortD},
+		{s: "B", want: OutputPortB},
+		{s: "MC", want: OutputPortC},
+		{s: "", err: true},
+		{s: "in5", err: true},
+		{s: "inA", err: true},
+		{s: "out1", err: true},
+		{s: "outE", err: true},
+		{s: "SA", err: true},
+		{s: "M1", err: true},
+		{s: "in12", err: true},
+	}
+	for _, test := range tests {
+		got, err := ParsePortName(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParsePortName(%q) = %v, <nil>; want error", test.s, got)
+			}
+			continue
+		}
+		if got != test.want || err != nil {
+			t.Errorf("ParsePortName(%q) = %v, %v; want %v, <nil>", test.s, got, err, test.want)
+		}
+	}
+}
+
+func TestPortNameString(t *testing.T) {
+	for name := InputPort1; name <= OutputPortD; name++ {
+		got, err := ParsePortName(name.String())
+		if got != name || err != nil {
+			t.Errorf("ParsePortName(%q) = %v, %v; want %v, <nil>", name.String(), got, err, name)
+		}
+	}
+}
+
+func TestPortAddress(t *testing.T) {
+	tests := []struct {
+		platform Platform
+		name     PortName
+		want     string
+	}{
+		{EV3, InputPort1, "ev3-ports:in1"},
+		{EV3, OutputPortD, "ev3-ports:outD"},
+		{BrickPi, InputPort1, "serial0-0:S1"},
+		{BrickPi, OutputPortC, "serial0-0:MC"},
+		{BrickPi3, InputPort3, "spi0.1:S3"},
+		{BrickPi3, OutputPortB, "spi0.1:MB"},
+		{PiStorms, InputPort3, "pistorms:BBS1"},
+	}
+	for _, test := range tests {
+		got, err := test.platform.PortAddress(test.name)
+		if got != test.want || err != nil {
+			t.Errorf("%v.PortAddress(%v) = %q, %v; want %q, <nil>", test.platform, test.name, got, err, test.want)
+		}
+	}
+	if got, err := UnknownPlatform.PortAddress(InputPort1); err == nil {
+		t.Errorf("UnknownPlatform.PortAddress(InputPort1) = %q, <nil>; want error", got)
+	}
+	if got, err := EV3.PortAddress(0); err == nil {
+		t.Errorf("EV3.PortAddress(0) = %q, <nil>; want error", got)
+	}
+}
+
+func TestPortByName(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sys/class/board-info/board0/uevent": "BOARD_INFO_MODEL=LEGO MINDSTORMS EV3\nBOARD_INFO_TYPE=main\n",
+		"sys/class/lego-port/port0/address":  "ev3-ports:in1\n",
+		"sys/class/lego-port/port1/address":  "ev3-ports:outB\n",
+	})
+	p, err := newBrick(root).PortByName(OutputPortB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Addr(), "ev3-ports:outB"; got != want {
+		t.Errorf("PortByName(OutputPortB).Addr() = %q; want %q", got, want)
+	}
+}
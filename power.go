@@ -0,0 +1,251 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Voltage is an electric potential in microvolts.
+type Voltage int32
+
+// Volts returns the voltage in volts.
+func (v Voltage) Volts() float64 {
+	return float64(v) / 1e6
+}
+
+// String formats the voltage in volts, like "7.512V".
+func (v Voltage) String() string {
+	return strconv.FormatFloat(v.Volts(), 'f', -1, 64) + "V"
+}
+
+// Current is an electric current in microamperes.
+type Current int32
+
+// Amps returns the current in amperes.
+func (c Current) Amps() float64 {
+	return float64(c) / 1e6
+}
+
+// String formats the current in amperes, like "0.185A".
+func (c Current) String() string {
+	return strconv.FormatFloat(c.Amps(), 'f', -1, 64) + "A"
+}
+
+// A PowerSupply is the battery powering the brick.
+type PowerSupply struct {
+	name       string
+	technology string
+	maxVoltage Voltage
+	minVoltage Voltage
+	voltage    *os.File
+	current    *os.File
+}
+
+// OpenPowerSupply opens the brick's battery. On an EV3, this is
+// lego-ev3-battery. On other platforms, it is the first power supply whose
+// name ends in "-battery".
+func (brick *Brick) OpenPowerSupply() (*PowerSupply, error) {
+	dir := filepath.Join(brick.root, "sys", "class", "power_supply")
+	name, err := findBattery(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open power supply: %w", err)
+	}
+	ps, err := newPowerSupply(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("open power supply %s: %w", name, err)
+	}
+	return ps, nil
+}
+
+func findBattery(dir string) (string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return "", err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(names)
+	const ev3Battery = "lego-ev3-battery"
+	for _, name := range names {
+		if name == ev3Battery {
+			return name, nil
+		}
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "-battery") {
+			return name, nil
+		}
+	}
+	return "", errors.New("no battery found")
+}
+
+func newPowerSupply(path string) (_ *PowerSupply, err error) {
+	ps := &PowerSupply{name: filepath.Base(path)}
+	techFile, err := os.Open(filepath.Join(path, "technology"))
+	if err == nil {
+		var buf [32]byte
+		var n int
+		n, err = readAttrBytes(techFile, buf[:])
+		techFile.Close()
+		ps.technology = string(buf[:n])
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if ps.maxVoltage, err = readOptionalVoltage(filepath.Join(path, "voltage_max_design")); err != nil {
+		return nil, err
+	}
+	if ps.minVoltage, err = readOptionalVoltage(filepath.Join(path, "voltage_min_design")); err != nil {
+		return nil, err
+	}
+	if ps.voltage, err = os.Open(filepath.Join(path, "voltage_now")); err != nil {
+		return nil, err
+	}
+	ps.current, err = os.Open(filepath.Join(path, "current_now"))
+	if errors.Is(err, os.ErrNotExist) {
+		ps.current = nil
+	} else if err != nil {
+		ps.voltage.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// readOptionalVoltage reads a voltage attribute, returning zero if the
+// attribute does not exist.
+func readOptionalVoltage(path string) (Voltage, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := readAttrInt(f, 32)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+	return Voltage(v), nil
+}
+
+// Close releases the power supply's resources.
+func (ps *PowerSupply) Close() error {
+	firstErr := ps.voltage.Close()
+	if ps.current != nil {
+		if err := ps.current.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("close power supply: %w", firstErr)
+	}
+	return nil
+}
+
+// Name returns the name of the power supply, like "lego-ev3-battery".
+func (ps *PowerSupply) Name() string {
+	return ps.name
+}
+
+// Technology returns the battery chemistry reported by the driver, like
+// "Li-ion" for the EV3 rechargeable battery pack or "Unknown" for AA
+// batteries. It is empty if the driver does not report it.
+func (ps *PowerSupply) Technology() string {
+	return ps.technology
+}
+
+// MaxVoltage returns the design voltage of a full battery or zero if
+// the driver does not report it.
+func (ps *PowerSupply) MaxVoltage() Voltage {
+	return ps.maxVoltage
+}
+
+// MinVoltage returns the design voltage of an empty battery or zero if
+// the driver does not report it.
+func (ps *PowerSupply) MinVoltage() Voltage {
+	return ps.minVoltage
+}
+
+// Voltage reads the present battery voltage.
+func (ps *PowerSupply) Voltage() (Voltage, error) {
+	v, err := readAttrInt(ps.voltage, 32)
+	if err != nil {
+		return 0, fmt.Errorf("read battery voltage: %w", err)
+	}
+	return Voltage(v), nil
+}
+
+// Current reads the present current drawn from the battery.
+func (ps *PowerSupply) Current() (Current, error) {
+	if ps.current == nil {
+		return 0, errors.New("read battery current: not reported by driver")
+	}
+	i, err := readAttrInt(ps.current, 32)
+	if err != nil {
+		return 0, fmt.Errorf("read battery current: %w", err)
+	}
+	return Current(i), nil
+}
+
+// WatchLowVoltage reads the battery voltage at the given interval and calls
+// f when the voltage drops below threshold. f is called again only after
+// the voltage has risen back to or above threshold and dropped again.
+// WatchLowVoltage blocks until ctx is done or reading the voltage fails.
+// An interval of zero or less means one second.
+func (ps *PowerSupply) WatchLowVoltage(ctx context.Context, threshold Voltage, interval time.Duration, f func(Voltage)) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	if err := watchLowVoltage(ctx, threshold, ticker.C, ps.Voltage, f); err != nil {
+		return fmt.Errorf("watch battery voltage: %w", err)
+	}
+	return nil
+}
+
+func watchLowVoltage(ctx context.Context, threshold Voltage, tick <-chan time.Time, read func() (Voltage, error), f func(Voltage)) error {
+	low := false
+	for {
+		v, err := read()
+		if err != nil {
+			return err
+		}
+		if v < threshold && !low {
+			f(v)
+		}
+		low = v < threshold
+		select {
+		case <-tick:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
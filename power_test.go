@@ -0,0 +1,115 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPowerSupply(t *testing.T) {
+	const dir = "sys/class/power_supply/lego-ev3-battery"
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sys/class/power_supply/AC/online": "0\n",
+		dir + "/technology":                "Li-ion\n",
+		dir + "/voltage_max_design":        "8400000\n",
+		dir + "/voltage_min_design":        "6000000\n",
+		dir + "/voltage_now":               "7512000\n",
+		dir + "/current_now":               "185000\n",
+	})
+	ps, err := newBrick(root).OpenPowerSupply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	if got, want := ps.Name(), "lego-ev3-battery"; got != want {
+		t.Errorf("Name() = %q; want %q", got, want)
+	}
+	if got, want := ps.Technology(), "Li-ion"; got != want {
+		t.Errorf("Technology() = %q; want %q", got, want)
+	}
+	if got, want := ps.MaxVoltage(), Voltage(8400000); got != want {
+		t.Errorf("MaxVoltage() = %v; want %v", got, want)
+	}
+	if got, want := ps.MinVoltage(), Voltage(6000000); got != want {
+		t.Errorf("MinVoltage() = %v; want %v", got, want)
+	}
+	if got, err := ps.Voltage(); got != 7512000 || err != nil {
+		t.Errorf("Voltage() = %v, %v; want 7.512V, <nil>", got, err)
+	}
+	if got, err := ps.Current(); got != 185000 || err != nil {
+		t.Errorf("Current() = %v, %v; want 0.185A, <nil>", got, err)
+	}
+}
+
+func TestWatchLowVoltage(t *testing.T) {
+	readings := []Voltage{7000000, 6500000, 6400000, 7000000, 6300000, 6200000}
+	errDone := errors.New("out of readings")
+	read := func() (Voltage, error) {
+		if len(readings) == 0 {
+			return 0, errDone
+		}
+		v := readings[0]
+		readings = readings[1:]
+		return v, nil
+	}
+	tick := make(chan time.Time)
+	close(tick)
+	var calls []Voltage
+	err := watchLowVoltage(context.Background(), 6800000, tick, read, func(v Voltage) {
+		calls = append(calls, v)
+	})
+	if !errors.Is(err, errDone) {
+		t.Errorf("watchLowVoltage(...) = %v; want %v", err, errDone)
+	}
+	want := []Voltage{6500000, 6300000}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("callback called with %v; want %v", calls, want)
+	}
+}
+
+func TestVoltageString(t *testing.T) {
+	if got, want := Voltage(7512000).String(), "7.512V"; got != want {
+		t.Errorf("Voltage(7512000).String() = %q; want %q", got, want)
+	}
+	if got, want := Current(185000).String(), "0.185A"; got != want {
+		t.Errorf("Current(185000).String() = %q; want %q", got, want)
+	}
+}
+
+func TestWatchLowVoltageZeroInterval(t *testing.T) {
+	const dir = "sys/class/power_supply/lego-ev3-battery"
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		dir + "/voltage_now": "7512000\n",
+	})
+	ps, err := newBrick(root).OpenPowerSupply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = ps.WatchLowVoltage(ctx, 6800000, 0, func(Voltage) {})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WatchLowVoltage(ctx, 6.8V, 0, f) = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
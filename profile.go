@@ -0,0 +1,233 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// A MotionProfile describes a smooth move over a distance that starts and
+// ends at rest without exceeding velocity, acceleration and jerk limits.
+// Profiles are unit-agnostic: if the distance is in tacho counts, the
+// velocity is in counts per second, the acceleration in counts per second
+// squared and the jerk in counts per second cubed.
+type MotionProfile struct {
+	distance float64
+	sign     float64
+	peak     float64
+	segments []profileSegment
+	duration float64
+}
+
+// A profileSegment is a span of time with constant jerk.
+type profileSegment struct {
+	duration float64
+	accel    float64 // at start of segment
+	jerk     float64
+}
+
+// NewMotionProfile computes a profile for moving the given distance.
+// Negative distances move backward. If maxJerk is zero, the profile is
+// trapezoidal: acceleration switches instantly between zero and
+// ±maxAcceleration. Otherwise, the profile is an S-curve in which
+// acceleration changes no faster than maxJerk. Short moves may never reach
+// maxVelocity or maxAcceleration.
+func NewMotionProfile(distance, maxVelocity, maxAcceleration, maxJerk float64) (*MotionProfile, error) {
+	switch {
+	case !(maxVelocity > 0):
+		return nil, fmt.Errorf("new motion profile: invalid max velocity %g", maxVelocity)
+	case !(maxAcceleration > 0):
+		return nil, fmt.Errorf("new motion profile: invalid max acceleration %g", maxAcceleration)
+	case !(maxJerk >= 0):
+		return nil, fmt.Errorf("new motion profile: invalid max jerk %g", maxJerk)
+	case math.IsNaN(distance) || math.IsInf(distance, 0):
+		return nil, fmt.Errorf("new motion profile: invalid distance %g", distance)
+	}
+	p := &MotionProfile{distance: distance, sign: 1}
+	if distance < 0 {
+		p.sign = -1
+	}
+	d := math.Abs(distance)
+	if d == 0 {
+		return p, nil
+	}
+	if maxJerk == 0 {
+		p.trapezoid(d, maxVelocity, maxAcceleration)
+	} else {
+		p.sCurve(d, maxVelocity, maxAcceleration, maxJerk)
+	}
+	for _, seg := range p.segments {
+		p.duration += seg.duration
+	}
+	return p, nil
+}
+
+func (p *MotionProfile) trapezoid(d, v, a float64) {
+	p.peak = math.Min(v, math.Sqrt(d*a))
+	ta := p.peak / a
+	tc := (d - p.peak*ta) / p.peak
+	p.segments = []profileSegment{
+		{duration: ta, accel: a},
+		{duration: tc},
+		{duration: ta, accel: -a},
+	}
+}
+
+func (p *MotionProfile) sCurve(d, v, a, j float64) {
+	// accelPhase returns the duration of the jerk and constant acceleration
+	// parts of accelerating from rest to vp, along with the peak
+	// acceleration.
+	accelPhase := func(vp float64) (tj, tca, ap float64) {
+		ap = a
+		if vp < a*a/j {
+			ap = math.Sqrt(vp * j)
+		}
+		tj = ap / j
+		tca = vp/ap - tj
+		return tj, tca, ap
+	}
+	// By symmetry, the average velocity while accelerating is vp/2.
+	accelDistance := func(vp float64) float64 {
+		tj, tca, _ := accelPhase(vp)
+		return vp * (2*tj + tca) / 2
+	}
+	vp := v
+	if 2*accelDistance(vp) > d {
+		// Search for the peak velocity that covers the distance exactly.
+		lo, hi := 0.0, v
+		for i := 0; i < 100; i++ {
+			mid := (lo + hi) / 2
+			if 2*accelDistance(mid) > d {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		vp = lo
+	}
+	p.peak = vp
+	tj, tca, ap := accelPhase(vp)
+	tc := (d - 2*accelDistance(vp)) / vp
+	p.segments = []profileSegment{
+		{duration: tj, jerk: j},
+		{duration: tca, accel: ap},
+		{duration: tj, accel: ap, jerk: -j},
+		{duration: tc},
+		{duration: tj, jerk: -j},
+		{duration: tca, accel: -ap},
+		{duration: tj, accel: -ap, jerk: j},
+	}
+}
+
+// Distance returns the distance the profile moves.
+func (p *MotionProfile) Distance() float64 {
+	return p.distance
+}
+
+// PeakVelocity returns the highest speed reached during the profile. It is
+// always positive, even for negative distances.
+func (p *MotionProfile) PeakVelocity() float64 {
+	return p.peak
+}
+
+// Duration returns how long the profile takes to complete.
+func (p *MotionProfile) Duration() time.Duration {
+	return time.Duration(p.duration * float64(time.Second))
+}
+
+// At returns the position and velocity at time t after the start of the
+// profile. Before the start, the position is zero and after the end, the
+// position is Distance. The velocity is zero outside the profile.
+func (p *MotionProfile) At(t time.Duration) (position, velocity float64) {
+	if t <= 0 {
+		return 0, 0
+	}
+	if t >= p.Duration() {
+		return p.distance, 0
+	}
+	remaining := t.Seconds()
+	var x, v float64
+	for _, seg := range p.segments {
+		dt := math.Min(remaining, seg.duration)
+		x += v*dt + seg.accel*dt*dt/2 + seg.jerk*dt*dt*dt/6
+		v += seg.accel*dt + seg.jerk*dt*dt/2
+		remaining -= dt
+		if remaining <= 0 {
+			break
+		}
+	}
+	return p.sign * x, p.sign * v
+}
+
+// FollowProfile moves the motor through the profile, whose units must be
+// tacho counts. Every interval, the motor is sent the speed that the
+// profile calls for with Run. Once the profile is complete, the motor is
+// sent to the final position with RunToPosition at a quarter of the
+// profile's peak velocity to correct any error accumulated along the way.
+// FollowProfile blocks until the final command has been sent, but does not
+// wait for the motor to reach the final position.
+//
+// If ctx is done before the profile is complete, the motor is stopped with
+// the given action and FollowProfile returns ctx.Err().
+//
+// An interval of zero or less means 20ms.
+func (m *TachoMotor) FollowProfile(ctx context.Context, p *MotionProfile, interval time.Duration, action StopAction) error {
+	if interval <= 0 {
+		interval = 20 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := time.Now()
+	elapsed := func() time.Duration { return time.Since(start) }
+	if err := m.followProfile(ctx, p, ticker.C, elapsed, action); err != nil {
+		return fmt.Errorf("follow motion profile: %w", err)
+	}
+	return nil
+}
+
+func (m *TachoMotor) followProfile(ctx context.Context, p *MotionProfile, tick <-chan time.Time, elapsed func() time.Duration, action StopAction) error {
+	start, err := m.Position()
+	if err != nil {
+		return err
+	}
+	for {
+		t := elapsed()
+		if t >= p.Duration() {
+			break
+		}
+		_, v := p.At(t)
+		if err := m.Run(TachoSpeed(math.Round(v))); err != nil {
+			m.Stop(action)
+			return err
+		}
+		select {
+		case <-tick:
+		case <-ctx.Done():
+			m.Stop(action)
+			return ctx.Err()
+		}
+	}
+	speed := TachoSpeed(math.Ceil(p.PeakVelocity() / 4))
+	if speed < 1 {
+		speed = 1
+	}
+	end := start.Add(TachoDelta(math.Round(p.Distance())))
+	return m.RunToPosition(end, &TachoMotorParams{Speed: speed, StopAction: action})
+}
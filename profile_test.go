@@ -0,0 +1,259 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func TestMotionProfile(t *testing.T) {
+	type point struct {
+		t        float64
+		position float64
+		velocity float64
+	}
+	tests := []struct {
+		name     string
+		distance float64
+		v, a, j  float64
+		duration float64
+		points   []point
+	}{
+		{
+			name:     "Trapezoid",
+			distance: 1000, v: 500, a: 1000,
+			duration: 2.5,
+			points: []point{
+				{-1, 0, 0},
+				{0.25, 31.25, 250},
+				{0.5, 125, 500},
+				{1.25, 500, 500},
+				{2.25, 968.75, 250},
+				{3, 1000, 0},
+			},
+		},
+		{
+			name:     "TrapezoidBackward",
+			distance: -1000, v: 500, a: 1000,
+			duration: 2.5,
+			points: []point{
+				{0.5, -125, -500},
+				{1.25, -500, -500},
+				{3, -1000, 0},
+			},
+		},
+		{
+			name:     "Triangle",
+			distance: 250, v: 1000, a: 1000,
+			duration: 1,
+			points: []point{
+				{0.5, 125, 500},
+				{0.75, 218.75, 250},
+			},
+		},
+		{
+			name:     "SCurve",
+			distance: 1000, v: 500, a: 1000, j: 5000,
+			duration: 2.7,
+			points: []point{
+				{0.2, 5000 * 0.008 / 6, 100},
+				{1.35, 500, 500},
+				{2.7, 1000, 0},
+			},
+		},
+		{
+			name:     "Zero",
+			distance: 0, v: 500, a: 1000, j: 5000,
+			duration: 0,
+			points: []point{
+				{1, 0, 0},
+			},
+		},
+	}
+	const eps = 1e-6
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := NewMotionProfile(test.distance, test.v, test.a, test.j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := p.Duration(), seconds(test.duration); got-want > time.Microsecond || want-got > time.Microsecond {
+				t.Errorf("Duration() = %v; want %v", got, want)
+			}
+			for _, pt := range test.points {
+				x, v := p.At(seconds(pt.t))
+				if math.Abs(x-pt.position) > eps || math.Abs(v-pt.velocity) > eps {
+					t.Errorf("At(%v) = %g, %g; want %g, %g", seconds(pt.t), x, v, pt.position, pt.velocity)
+				}
+			}
+		})
+	}
+}
+
+func TestMotionProfileLimits(t *testing.T) {
+	tests := []struct {
+		distance float64
+		v, a, j  float64
+	}{
+		{1000, 500, 1000, 0},
+		{1000, 500, 1000, 5000},
+		{10, 500, 1000, 5000},
+		{150, 500, 1000, 2000},
+		{-300, 400, 800, 1000},
+	}
+	for _, test := range tests {
+		p, err := NewMotionProfile(test.distance, test.v, test.a, test.j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		const steps = 10000
+		dt := p.Duration() / steps
+		var prevV, prevA float64
+		for i := 1; i <= steps; i++ {
+			_, v := p.At(dt * time.Duration(i))
+			a := (v - prevV) / dt.Seconds()
+			if math.Abs(v) > test.v*(1+1e-9) {
+				t.Errorf("profile %+v: |v| = %g at %v; want <= %g", test, math.Abs(v), dt*time.Duration(i), test.v)
+				break
+			}
+			// Allow for the discretization of the numerical derivative.
+			if math.Abs(a) > test.a*1.01 {
+				t.Errorf("profile %+v: |a| = %g at %v; want <= %g", test, math.Abs(a), dt*time.Duration(i), test.a)
+				break
+			}
+			if test.j > 0 && i > 1 && math.Abs(a-prevA)/dt.Seconds() > test.j*1.01 {
+				t.Errorf("profile %+v: |j| = %g at %v; want <= %g", test, math.Abs(a-prevA)/dt.Seconds(), dt*time.Duration(i), test.j)
+				break
+			}
+			prevV, prevA = v, a
+		}
+		end, _ := p.At(p.Duration() - time.Nanosecond)
+		if math.Abs(end-test.distance) > 1e-3 {
+			t.Errorf("profile %+v: position at end = %g; want %g", test, end, test.distance)
+		}
+	}
+}
+
+func TestMotionProfileInvalid(t *testing.T) {
+	tests := []struct {
+		distance, v, a, j float64
+	}{
+		{100, 0, 1000, 0},
+		{100, 500, -1, 0},
+		{100, 500, 1000, -1},
+		{math.NaN(), 500, 1000, 0},
+	}
+	for _, test := range tests {
+		if _, err := NewMotionProfile(test.distance, test.v, test.a, test.j); err == nil {
+			t.Errorf("NewMotionProfile(%g, %g, %g, %g) did not return an error", test.distance, test.v, test.a, test.j)
+		}
+	}
+}
+
+func TestFollowProfile(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	writeFiles(t, motorsDir, map[string]string{"motor0/position": "100\n"})
+	p, err := NewMotionProfile(1000, 500, 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	times := []time.Duration{0, seconds(0.25), seconds(1.25), seconds(3)}
+	var speeds []string
+	elapsed := func() time.Duration {
+		speeds = append(speeds, readFile(t, motorsDir, "motor0/speed_sp"))
+		t := times[0]
+		times = times[1:]
+		return t
+	}
+	tick := make(chan time.Time)
+	close(tick)
+	if err := m.followProfile(context.Background(), p, tick, elapsed, Hold); err != nil {
+		t.Fatal(err)
+	}
+	// Opening the motor truncates speed_sp, so it starts out empty.
+	wantSpeeds := []string{"", "0", "250", "500"}
+	if len(speeds) != len(wantSpeeds) {
+		t.Fatalf("speeds = %q; want %q", speeds, wantSpeeds)
+	}
+	for i := range wantSpeeds {
+		if speeds[i] != wantSpeeds[i] {
+			t.Errorf("speeds = %q; want %q", speeds, wantSpeeds)
+			break
+		}
+	}
+	want := map[string]string{
+		"motor0/command":     "run-to-abs-pos",
+		"motor0/position_sp": "1100",
+		"motor0/speed_sp":    "125",
+		"motor0/stop_action": "hold",
+	}
+	for fname, want := range want {
+		if got := readFile(t, motorsDir, fname); got != want {
+			t.Errorf("%s = %q; want %q", fname, got, want)
+		}
+	}
+}
+
+func TestFollowProfileCancel(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	p, err := NewMotionProfile(1000, 500, 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = m.followProfile(ctx, p, nil, func() time.Duration { return seconds(1) }, Brake)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("followProfile(...) = %v; want %v", err, context.Canceled)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "stop"; got != want {
+		t.Errorf("command = %q; want %q", got, want)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/stop_action"), "brake"; got != want {
+		t.Errorf("stop_action = %q; want %q", got, want)
+	}
+}
+
+func TestFollowProfileZeroInterval(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	p, err := NewMotionProfile(1000, 500, 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.FollowProfile(ctx, p, 0, Brake); !errors.Is(err, context.Canceled) {
+		t.Errorf("FollowProfile(ctx, p, 0, Brake) = %v; want %v", err, context.Canceled)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "stop"; got != want {
+		t.Errorf("command = %q; want %q", got, want)
+	}
+}
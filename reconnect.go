@@ -0,0 +1,134 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// reconnectPollInterval is how often a device waiting to reconnect checks
+// whether a new device has appeared at its address.
+const reconnectPollInterval = 100 * time.Millisecond
+
+// waitForDevice releases the device at addr in dir and waits up to timeout
+// for a new device to appear at the same address. It returns the new
+// device's path.
+func (devs *devices) waitForDevice(dir *deviceDir, addr address, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	devs.mu.Lock()
+	dir.unclaim(addr)
+	devs.mu.Unlock()
+	for {
+		devs.mu.Lock()
+		path, err := dir.findByAddress(addr)
+		devs.mu.Unlock()
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+		if !time.Now().Before(deadline) {
+			return "", fmt.Errorf("wait for device %q: timed out after %v: %w", addr, timeout, ErrNotFound)
+		}
+		time.Sleep(reconnectPollInterval)
+	}
+}
+
+// A reconnector coordinates reconnecting a device whose operations are
+// serialized by a mutex. The mutex is released while waiting for the new
+// device, so that operations that don't need the device, like stopping or
+// closing it, are not stuck behind the wait. Only one reconnect runs at a
+// time: operations that fail while one is in progress wait for it to finish
+// instead of starting another.
+type reconnector struct {
+	// attempt is the reconnect in progress or nil, and n is the number of
+	// successful reconnects. Both are protected by the device's mutex.
+	attempt *reconnectAttempt
+	n       int
+}
+
+type reconnectAttempt struct {
+	done chan struct{}
+	// err is the result of the reconnect. It is set before done is closed.
+	err error
+}
+
+// reconnecting reports whether a reconnect is in progress. The caller must
+// be holding onto the device's mutex.
+func (r *reconnector) reconnecting() bool {
+	return r.attempt != nil
+}
+
+// retry calls f. If f fails because the device was removed and timeout is
+// positive, retry reconnects and then calls f one more time. If a reconnect
+// is already in progress, retry waits for it to finish before calling f.
+// The caller must be holding onto mu.
+func (r *reconnector) retry(mu *sync.Mutex, timeout time.Duration, wait func() (string, error), switchTo func(path string) error, f func() error) error {
+	if r.attempt != nil {
+		if err := r.join(mu); err != nil {
+			return fmt.Errorf("%w (reconnect: %v)", ErrDeviceRemoved, err)
+		}
+		return f()
+	}
+	err := f()
+	if err == nil || timeout <= 0 || !errors.Is(err, ErrDeviceRemoved) {
+		return err
+	}
+	if rerr := r.reconnect(mu, wait, switchTo); rerr != nil {
+		return fmt.Errorf("%w (reconnect: %v)", err, rerr)
+	}
+	return f()
+}
+
+// reconnect calls wait with mu released to find the new device's path and
+// then calls switchTo with mu held to switch over to it. If a reconnect is
+// already in progress, reconnect waits for it to finish instead. The caller
+// must be holding onto mu.
+func (r *reconnector) reconnect(mu *sync.Mutex, wait func() (string, error), switchTo func(path string) error) error {
+	if r.attempt != nil {
+		return r.join(mu)
+	}
+	a := &reconnectAttempt{done: make(chan struct{})}
+	r.attempt = a
+	mu.Unlock()
+	path, err := wait()
+	mu.Lock()
+	if err == nil {
+		err = switchTo(path)
+	}
+	if err == nil {
+		r.n++
+	}
+	a.err = err
+	r.attempt = nil
+	close(a.done)
+	return err
+}
+
+// join waits for the reconnect in progress to finish and returns its error.
+// The caller must be holding onto mu, which is released while waiting.
+func (r *reconnector) join(mu *sync.Mutex) error {
+	a := r.attempt
+	mu.Unlock()
+	<-a.done
+	mu.Lock()
+	return a.err
+}
@@ -0,0 +1,450 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// errRemoved is the error a read returns after the device was unplugged.
+var errRemoved = fmt.Errorf("read attribute position: %w", wrapErrno(&os.PathError{
+	Op:   "read",
+	Path: "position",
+	Err:  unix.ENODEV,
+}))
+
+func fakeTachoMotorFiles(dir, addr string, position int) map[string]string {
+	return map[string]string{
+		dir + "/address":       addr + "\n",
+		dir + "/command":       "",
+		dir + "/count_per_rot": "360\n",
+		dir + "/max_speed":     "1050\n",
+		dir + "/polarity":      "normal\n",
+		dir + "/position":      fmt.Sprintf("%d\n", position),
+		dir + "/position_sp":   "0\n",
+		dir + "/speed":         "0\n",
+		dir + "/speed_sp":      "0\n",
+		dir + "/state":         "\n",
+		dir + "/stop_action":   "coast\n",
+		dir + "/stop_actions":  "coast brake hold\n",
+		dir + "/time_sp":       "0\n",
+	}
+}
+
+// openFakeTachoMotor opens a fake motor at the given address without going
+// through its port.
+func openFakeTachoMotor(tb testing.TB, brick *Brick, addr string) *TachoMotor {
+	tb.Helper()
+	a, err := newAddress(addr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path, err := brick.devices.tachoMotors.findByAddress(a)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	m, err := newTachoMotor(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	m.addr = a
+	m.devices = brick.devices
+	brick.devices.mu.Lock()
+	brick.devices.addMotor(m)
+	brick.devices.mu.Unlock()
+	tb.Cleanup(func() { m.Close() })
+	return m
+}
+
+func fakeSensorFiles(dir, addr string) map[string]string {
+	return map[string]string{
+		dir + "/address":    addr + "\n",
+		dir + "/decimals":   "0\n",
+		dir + "/mode":       "COL-REFLECT\n",
+		dir + "/num_values": "1\n",
+		dir + "/value0":     "17\n",
+	}
+}
+
+// openFakeSensor opens a fake sensor at the given address without going
+// through its port.
+func openFakeSensor(tb testing.TB, brick *Brick, addr string) *Sensor {
+	tb.Helper()
+	a, err := newAddress(addr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	path, err := brick.devices.sensors.findByAddress(a)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s, err := newSensor(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s.addr = a
+	s.devices = brick.devices
+	tb.Cleanup(func() { s.Close() })
+	return s
+}
+
+// newFakeTachoMotor opens a fake motor at the given address in a new fake
+// sysfs tree. The motor's files are in motorsDir under motor0.
+func newFakeTachoMotor(tb testing.TB, addr string) (_ *TachoMotor, motorsDir string) {
+	tb.Helper()
+	root := tb.TempDir()
+	motorsDir = filepath.Join(root, "sys", "class", "tacho-motor")
+	writeFiles(tb, motorsDir, fakeTachoMotorFiles("motor0", addr, 0))
+	return openFakeTachoMotor(tb, newBrick(root), addr), motorsDir
+}
+
+func TestTachoMotorReconnect(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	m.SetReconnectTimeout(time.Second)
+	if err := m.SetPolarity(InversedPolarity); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/polarity"), "inversed"; got != want {
+		t.Errorf("motor0/polarity = %q; want %q", got, want)
+	}
+
+	// Unplug and plug the motor back in.
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor1", addr, 42))
+
+	calls := 0
+	m.mu.Lock()
+	err := m.retry(func() error {
+		calls++
+		if calls == 1 {
+			return errRemoved
+		}
+		return nil
+	})
+	m.mu.Unlock()
+	if err != nil {
+		t.Fatal("retry:", err)
+	}
+	if calls != 2 {
+		t.Errorf("retry called f %d times; want 2", calls)
+	}
+	if got, err := m.Position(); got != 42 || err != nil {
+		t.Errorf("Position() = %d, %v; want 42, <nil>", got, err)
+	}
+	if got, want := readFile(t, motorsDir, "motor1/polarity"), "inversed"; got != want {
+		t.Errorf("motor1/polarity = %q; want %q", got, want)
+	}
+}
+
+func TestTachoMotorReconnectTimeout(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	m.SetReconnectTimeout(time.Millisecond)
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mu.Lock()
+	err := m.retry(func() error { return errRemoved })
+	m.mu.Unlock()
+	if !errors.Is(err, ErrDeviceRemoved) {
+		t.Errorf("retry = %v; want %v", err, ErrDeviceRemoved)
+	}
+}
+
+func TestTachoMotorReconnectDisabled(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, _ := newFakeTachoMotor(t, addr)
+
+	calls := 0
+	m.mu.Lock()
+	err := m.retry(func() error {
+		calls++
+		return errRemoved
+	})
+	m.mu.Unlock()
+	if !errors.Is(err, ErrDeviceRemoved) {
+		t.Errorf("retry = %v; want %v", err, ErrDeviceRemoved)
+	}
+	if calls != 1 {
+		t.Errorf("retry called f %d times; want 1", calls)
+	}
+}
+
+func TestSensorReconnect(t *testing.T) {
+	const addr = "ev3-ports:in1"
+	root := t.TempDir()
+	sensorsDir := filepath.Join(root, "sys", "class", "lego-sensor")
+	writeFiles(t, sensorsDir, fakeSensorFiles("sensor0", addr))
+	s := openFakeSensor(t, newBrick(root), addr)
+	s.SetReconnectTimeout(time.Second)
+	if err := s.SetMode("RGB-RAW"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unplug and plug the sensor back in.
+	if err := os.RemoveAll(filepath.Join(sensorsDir, "sensor0")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, sensorsDir, map[string]string{
+		"sensor1/address":    addr + "\n",
+		"sensor1/decimals":   "0\n",
+		"sensor1/mode":       "COL-REFLECT\n",
+		"sensor1/num_values": "3\n",
+		"sensor1/value0":     "1\n",
+		"sensor1/value1":     "2\n",
+		"sensor1/value2":     "3\n",
+	})
+
+	calls := 0
+	s.mu.Lock()
+	err := s.retry(func() error {
+		calls++
+		if calls == 1 {
+			return errRemoved
+		}
+		return nil
+	})
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatal("retry:", err)
+	}
+	if got, want := readFile(t, sensorsDir, "sensor1/mode"), "RGB-RAW"; got != want {
+		t.Errorf("sensor1/mode = %q; want %q", got, want)
+	}
+	if got := s.NValues(); got != 3 {
+		t.Errorf("NValues() = %d; want 3", got)
+	}
+}
+
+func TestTachoMotorSetPolarityRemoved(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.SetPolarity(InversedPolarity); !errors.Is(err, ErrDeviceRemoved) {
+		t.Errorf("SetPolarity(InversedPolarity) = %v; want %v", err, ErrDeviceRemoved)
+	}
+
+	// Plugging the motor back in lets SetPolarity reconnect.
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor1", addr, 0))
+	m.SetReconnectTimeout(time.Second)
+	if err := m.SetPolarity(InversedPolarity); err != nil {
+		t.Fatal("SetPolarity after plugging back in:", err)
+	}
+	if got, want := readFile(t, motorsDir, "motor1/polarity"), "inversed"; got != want {
+		t.Errorf("motor1/polarity = %q; want %q", got, want)
+	}
+}
+
+func TestTachoMotorReconnectAfterReset(t *testing.T) {
+	for _, reset := range []struct {
+		name string
+		f    func(m *TachoMotor) error
+	}{
+		{"Motor", (*TachoMotor).Reset},
+		{"Group", func(m *TachoMotor) error { return NewMotorGroup(m).Reset() }},
+	} {
+		t.Run(reset.name, func(t *testing.T) {
+			const addr = "ev3-ports:outA"
+			m, motorsDir := newFakeTachoMotor(t, addr)
+			m.SetReconnectTimeout(time.Second)
+			if err := m.SetPolarity(InversedPolarity); err != nil {
+				t.Fatal(err)
+			}
+			if err := reset.f(m); err != nil {
+				t.Fatal("Reset:", err)
+			}
+
+			// Unplug and plug the motor back in.
+			if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+				t.Fatal(err)
+			}
+			files := fakeTachoMotorFiles("motor1", addr, 0)
+			files["motor1/polarity"] = "unchanged\n"
+			writeFiles(t, motorsDir, files)
+			calls := 0
+			m.mu.Lock()
+			err := m.retry(func() error {
+				calls++
+				if calls == 1 {
+					return errRemoved
+				}
+				return nil
+			})
+			m.mu.Unlock()
+			if err != nil {
+				t.Fatal("retry:", err)
+			}
+			if got, want := readFile(t, motorsDir, "motor1/polarity"), "unchanged\n"; got != want {
+				t.Errorf("motor1/polarity = %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSensorSetModeRemoved(t *testing.T) {
+	const addr = "ev3-ports:in1"
+	root := t.TempDir()
+	sensorsDir := filepath.Join(root, "sys", "class", "lego-sensor")
+	writeFiles(t, sensorsDir, fakeSensorFiles("sensor0", addr))
+	s := openFakeSensor(t, newBrick(root), addr)
+	if err := os.RemoveAll(filepath.Join(sensorsDir, "sensor0")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetMode("RGB-RAW"); !errors.Is(err, ErrDeviceRemoved) {
+		t.Errorf("SetMode(%q) = %v; want %v", "RGB-RAW", err, ErrDeviceRemoved)
+	}
+
+	// Plugging the sensor back in lets SetMode reconnect.
+	writeFiles(t, sensorsDir, fakeSensorFiles("sensor1", addr))
+	s.SetReconnectTimeout(time.Second)
+	if err := s.SetMode("RGB-RAW"); err != nil {
+		t.Fatal("SetMode after plugging back in:", err)
+	}
+	if got, want := readFile(t, sensorsDir, "sensor1/mode"), "RGB-RAW"; got != want {
+		t.Errorf("sensor1/mode = %q; want %q", got, want)
+	}
+}
+
+func TestTachoMotorReconnectUnlocked(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	m.SetReconnectTimeout(10 * time.Second)
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fake files stay readable after they are removed, so simulate the
+	// first read failing.
+	errc := make(chan error, 2)
+	go func() {
+		calls := 0
+		m.mu.Lock()
+		errc <- m.retry(func() error {
+			calls++
+			if calls == 1 {
+				return errRemoved
+			}
+			return nil
+		})
+		m.mu.Unlock()
+	}()
+	waitForReconnecting(t, m)
+
+	// Other operations join the reconnect in progress.
+	want := filepath.Join(motorsDir, "motor1")
+	go func() {
+		m.mu.Lock()
+		errc <- m.retry(func() error {
+			if m.path != want {
+				return fmt.Errorf("path = %q; want %q", m.path, want)
+			}
+			return nil
+		})
+		m.mu.Unlock()
+	}()
+
+	// Stopping does not wait for the reconnect.
+	stopped := make(chan struct{})
+	go func() {
+		m.Stop(Brake)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("Stop(Brake) blocked during reconnect")
+	}
+
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor1", addr, 42))
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Error("retry:", err)
+		}
+	}
+	if got, err := m.Position(); got != 42 || err != nil {
+		t.Errorf("Position() = %d, %v; want 42, <nil>", got, err)
+	}
+}
+
+func TestTachoMotorCloseDuringReconnect(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	brick := &Brick{devices: m.devices}
+	m.SetReconnectTimeout(10 * time.Second)
+	if err := os.RemoveAll(filepath.Join(motorsDir, "motor0")); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		m.mu.Lock()
+		errc <- m.retry(func() error { return errRemoved })
+		m.mu.Unlock()
+	}()
+	waitForReconnecting(t, m)
+	if err := m.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor1", addr, 42))
+	if err := <-errc; err == nil {
+		t.Error("retry after Close = <nil>; want error")
+	}
+
+	// The new motor is available to be opened again.
+	a, err := newAddress(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brick.devices.mu.Lock()
+	path, err := brick.devices.tachoMotors.findByAddress(a)
+	brick.devices.mu.Unlock()
+	if want := filepath.Join(motorsDir, "motor1"); path != want || err != nil {
+		t.Errorf("findByAddress(%q) = %q, %v; want %q, <nil>", addr, path, err, want)
+	}
+}
+
+// waitForReconnecting waits until the motor starts waiting for a new device.
+func waitForReconnecting(tb testing.TB, m *TachoMotor) {
+	tb.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		reconnecting := m.reconnector.reconnecting()
+		m.mu.Unlock()
+		if reconnecting {
+			return
+		}
+		if time.Now().After(deadline) {
+			tb.Fatal("motor did not start reconnecting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
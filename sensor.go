@@ -17,9 +17,12 @@
 package ev3dev
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"zombiezen.com/go/ev3dev/fixedpoint"
 )
@@ -52,13 +55,27 @@ func (typ SensorType) driver() []byte {
 }
 
 // A Sensor represents an input device.
+//
+// A Sensor is safe to use from multiple goroutines.
 type Sensor struct {
+	// mu protects all of the fields below, including the files' contents.
+	mu sync.Mutex
+
+	path    string
+	addr    address
+	devices *devices
+
+	reconnectTimeout time.Duration
+	reconnector      reconnector
+	closed           bool
+	mode             string
+
 	decimals int16
 	values   [8]*os.File
 }
 
 func newSensor(path string) (_ *Sensor, err error) {
-	s := new(Sensor)
+	s := &Sensor{path: path}
 
 	decimalsFile, err := os.Open(filepath.Join(path, "decimals"))
 	if err != nil {
@@ -95,8 +112,24 @@ func newSensor(path string) (_ *Sensor, err error) {
 	return s, nil
 }
 
-// Close cleans up any resources for this sensor.
+// Close cleans up any resources for this sensor and lets the sensor be
+// opened again from its port.
 func (s *Sensor) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.devices != nil {
+		s.devices.mu.Lock()
+		s.devices.sensors.release(s.path, s.addr)
+		s.devices.mu.Unlock()
+	}
+	if err := s.closeFiles(); err != nil {
+		return fmt.Errorf("close sensor: %w", err)
+	}
+	return nil
+}
+
+func (s *Sensor) closeFiles() error {
 	var firstErr error
 	for _, f := range s.values {
 		if f == nil {
@@ -106,14 +139,108 @@ func (s *Sensor) Close() error {
 			firstErr = err
 		}
 	}
-	if firstErr != nil {
-		return fmt.Errorf("close sensor: %w", firstErr)
+	return firstErr
+}
+
+// SetReconnectTimeout enables automatic reconnection. When the sensor is
+// unplugged, the kernel removes its device and reads fail with
+// ErrDeviceRemoved. With reconnection enabled, a read that fails this way
+// waits up to timeout for a sensor to be plugged back into the same port,
+// reopens it, restores the mode set with SetMode and then retries the read
+// once.
+//
+// The sensor is not locked while waiting, so other goroutines can still
+// close it. Other reads made in the meantime wait for the same reconnect
+// instead of starting another.
+//
+// A timeout of zero (the default) disables reconnection.
+func (s *Sensor) SetReconnectTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reconnectTimeout = timeout
+}
+
+// SetMode changes the sensor's mode, like "COL-REFLECT". Changing the mode
+// may change the number of values the sensor provides.
+func (s *Sensor) SetMode(mode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.retry(func() error {
+		if err := writeSensorMode(s.path, mode); err != nil {
+			return err
+		}
+		return s.reopen(s.path)
+	})
+	if err != nil {
+		return fmt.Errorf("set sensor mode %q: %w", mode, err)
+	}
+	s.mode = mode
+	return nil
+}
+
+func writeSensorMode(path string, mode string) error {
+	f, err := openDeviceAttrWrite(path, "mode")
+	if err != nil {
+		return err
+	}
+	err = writeAttr(f, []byte(mode))
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// retry calls f, reconnecting and retrying if f fails because the device was
+// removed and reconnection is enabled. The caller must be holding onto s.mu,
+// which is released while waiting for the sensor to be plugged back in.
+func (s *Sensor) retry(f func() error) error {
+	if s.devices == nil {
+		return f()
+	}
+	devs, addr, timeout := s.devices, s.addr, s.reconnectTimeout
+	wait := func() (string, error) {
+		return devs.waitForDevice(&devs.sensors, addr, timeout)
+	}
+	return s.reconnector.retry(&s.mu, timeout, wait, s.switchTo, f)
+}
+
+// switchTo switches the sensor's files over to the new sensor at path. The
+// caller must be holding onto s.mu.
+func (s *Sensor) switchTo(path string) error {
+	if s.closed {
+		// Closed while waiting for the new sensor.
+		s.devices.mu.Lock()
+		s.devices.sensors.release(path, s.addr)
+		s.devices.mu.Unlock()
+		return errors.New("sensor closed")
+	}
+	if s.mode != "" {
+		if err := writeSensorMode(path, s.mode); err != nil {
+			return err
+		}
+	}
+	return s.reopen(path)
+}
+
+// reopen replaces the sensor's files with the ones for the device at path,
+// rereading its number of values and decimal places.
+func (s *Sensor) reopen(path string) error {
+	ns, err := newSensor(path)
+	if err != nil {
+		return err
 	}
+	s.closeFiles()
+	s.path = ns.path
+	s.decimals = ns.decimals
+	s.values = ns.values
 	return nil
 }
 
 // NValues returns the number of values the sensor provides.
 func (s *Sensor) NValues() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for i, f := range s.values {
 		if f == nil {
 			return i
@@ -125,10 +252,16 @@ func (s *Sensor) NValues() int {
 // Value reads the i'th value from the sensor. It returns an error if i is not
 // in the range [0, s.NValues()).
 func (s *Sensor) Value(i int) (fixedpoint.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if i < 0 || i >= len(s.values) || s.values[i] == nil {
 		return fixedpoint.Value{}, fmt.Errorf("read sensor value %d: no such value", i)
 	}
-	v, err := readAttrInt(s.values[i], 32)
+	var v int64
+	err := s.retry(func() (err error) {
+		v, err = readAttrInt(s.values[i], 32)
+		return err
+	})
 	if err != nil {
 		return fixedpoint.Value{}, fmt.Errorf("read sensor value %d: %w", i, err)
 	}
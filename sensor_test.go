@@ -0,0 +1,67 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSensorConcurrentUse(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"sensor0/decimals":   "1\n",
+		"sensor0/mode":       "COL-REFLECT\n",
+		"sensor0/num_values": "1\n",
+		"sensor0/value0":     "17\n",
+	})
+	s, err := newSensor(filepath.Join(root, "sensor0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := s.SetMode("COL-REFLECT"); err != nil {
+				t.Error("SetMode:", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			v, err := s.Value(0)
+			if err != nil {
+				t.Error("Value(0):", err)
+				return
+			}
+			if got, want := v.String(), "1.7"; got != want {
+				t.Errorf("Value(0) = %s; want %s", got, want)
+				return
+			}
+			s.NValues()
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// exitSignals runs the package's cleanup on termination signals, like
+// stopping motors for StopOnSignal and restoring an open VT.
+var exitSignals = &signalCoordinator{
+	notify:  signal.Notify,
+	ignored: signal.Ignored,
+	raise:   raiseDefault,
+}
+
+// A signalCoordinator runs every registered hook for a signal and waits for
+// all of them to return before performing the signal's default action.
+// Having each user of a signal re-raise it on its own would let the first
+// one to finish terminate the program while the others are still cleaning
+// up.
+//
+// Performing the default action resets the signal, which also removes any
+// channels the program registered with signal.Notify, so the program does
+// not get to run its own shutdown for the signal.
+type signalCoordinator struct {
+	notify  func(c chan<- os.Signal, sigs ...os.Signal)
+	ignored func(sig os.Signal) bool
+	raise   func(sig os.Signal)
+
+	mu       sync.Mutex
+	c        chan os.Signal
+	hooks    map[*signalHook]struct{}
+	notified map[os.Signal]bool
+}
+
+type signalHook struct {
+	sigs []os.Signal
+	f    func(os.Signal)
+}
+
+// add registers f to be called when the program receives one of sigs.
+// Signals that the program ignores, like SIGHUP under nohup, are skipped:
+// catching them would make them terminate the program. Calling the
+// returned function unregisters f.
+func (co *signalCoordinator) add(f func(os.Signal), sigs ...os.Signal) (remove func()) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	var caught []os.Signal
+	for _, sig := range sigs {
+		if co.notified[sig] || !co.ignored(sig) {
+			caught = append(caught, sig)
+		}
+	}
+	if len(caught) == 0 {
+		return func() {}
+	}
+	h := &signalHook{sigs: caught, f: f}
+	if co.c == nil {
+		co.c = make(chan os.Signal, 1)
+		co.hooks = make(map[*signalHook]struct{})
+		co.notified = make(map[os.Signal]bool)
+		go co.dispatch(co.c)
+	}
+	co.hooks[h] = struct{}{}
+	for _, sig := range caught {
+		if !co.notified[sig] {
+			co.notify(co.c, sig)
+			co.notified[sig] = true
+		}
+	}
+	return func() {
+		co.mu.Lock()
+		delete(co.hooks, h)
+		co.mu.Unlock()
+	}
+}
+
+func (co *signalCoordinator) dispatch(c <-chan os.Signal) {
+	for sig := range c {
+		co.handle(sig)
+	}
+}
+
+// handle calls the hooks registered for sig in parallel, waits for them to
+// return and then performs the signal's default action. Signals that
+// arrive without any hooks registered get the default action right away,
+// as if they had never been caught.
+func (co *signalCoordinator) handle(sig os.Signal) {
+	co.mu.Lock()
+	var hooks []func(os.Signal)
+	for h := range co.hooks {
+		for _, hs := range h.sigs {
+			if hs == sig {
+				hooks = append(hooks, h.f)
+				break
+			}
+		}
+	}
+	co.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(len(hooks))
+	for _, f := range hooks {
+		go func(f func(os.Signal)) {
+			defer wg.Done()
+			f(sig)
+		}(f)
+	}
+	wg.Wait()
+
+	// Hold co.mu so that a hook added meanwhile isn't reset by raise.
+	co.mu.Lock()
+	delete(co.notified, sig)
+	co.raise(sig)
+	co.mu.Unlock()
+}
+
+// raiseDefault stops catching sig and sends it to the program again, so that
+// its default action is performed.
+func raiseDefault(sig os.Signal) {
+	signal.Reset(sig)
+	if s, ok := sig.(syscall.Signal); ok {
+		unix.Kill(os.Getpid(), s)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSignalCoordinator(t *testing.T) {
+	var mu sync.Mutex
+	var notified []os.Signal
+	raised := make(chan os.Signal, 1)
+	co := &signalCoordinator{
+		notify: func(c chan<- os.Signal, sigs ...os.Signal) {
+			mu.Lock()
+			notified = append(notified, sigs...)
+			mu.Unlock()
+		},
+		ignored: func(os.Signal) bool { return false },
+		raise:   func(sig os.Signal) { raised <- sig },
+	}
+
+	// A slow hook, like stopping motors.
+	unblock := make(chan struct{})
+	slowDone := make(chan struct{})
+	co.add(func(os.Signal) {
+		<-unblock
+		close(slowDone)
+	}, unix.SIGINT, unix.SIGTERM)
+	// A hook that unregisters itself, like closing a VT.
+	var removeSelf func()
+	selfDone := make(chan struct{})
+	removeSelf = co.add(func(os.Signal) {
+		removeSelf()
+		close(selfDone)
+	}, unix.SIGINT)
+	// Hooks for other signals and removed hooks are not called.
+	co.add(func(os.Signal) { t.Error("SIGHUP hook called for SIGINT") }, unix.SIGHUP)
+	co.add(func(os.Signal) { t.Error("removed hook called") }, unix.SIGINT)()
+
+	mu.Lock()
+	if len(notified) != 3 {
+		t.Errorf("notified for %v; want each of SIGINT, SIGTERM and SIGHUP once", notified)
+	}
+	mu.Unlock()
+
+	go co.handle(unix.SIGINT)
+	<-selfDone
+	select {
+	case <-raised:
+		t.Fatal("signal raised before all hooks returned")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	if got := <-raised; got != unix.SIGINT {
+		t.Errorf("raised %v; want %v", got, unix.SIGINT)
+	}
+	select {
+	case <-slowDone:
+	default:
+		t.Error("signal raised before slow hook finished")
+	}
+}
+
+func TestSignalCoordinatorIgnored(t *testing.T) {
+	var notified []os.Signal
+	co := &signalCoordinator{
+		notify: func(c chan<- os.Signal, sigs ...os.Signal) {
+			notified = append(notified, sigs...)
+		},
+		// As under nohup.
+		ignored: func(sig os.Signal) bool { return sig == unix.SIGHUP },
+		raise:   func(os.Signal) {},
+	}
+	co.add(func(os.Signal) {}, unix.SIGINT, unix.SIGHUP)
+	if len(notified) != 1 || notified[0] != unix.SIGINT {
+		t.Errorf("notified for %v; want [%v]", notified, unix.SIGINT)
+	}
+	notified = nil
+	co.add(func(os.Signal) { t.Error("hook called") }, unix.SIGHUP)()
+	if len(notified) != 0 {
+		t.Errorf("notified for %v with only ignored signals; want none", notified)
+	}
+}
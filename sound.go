@@ -0,0 +1,251 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pcmVolumeControl is the name of the mixer control for PCM playback.
+const pcmVolumeControl = "PCM Playback Volume"
+
+// Sound plays sounds through the brick's speaker.
+type Sound struct {
+	mu      sync.Mutex
+	speaker *os.File
+
+	// openPCM and openVolume are replaced by fakes in tests.
+	openPCM    func(pcmConfig) (pcmDevice, error)
+	openVolume func() (volumeControl, error)
+}
+
+// OpenSound opens the brick's speaker.
+func (brick *Brick) OpenSound() (*Sound, error) {
+	path := filepath.Join(brick.root, "dev", "input", "by-path", "platform-sound-event")
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open sound: %w", err)
+	}
+	pcmPath := filepath.Join(brick.root, "dev", "snd", "pcmC0D0p")
+	controlPath := filepath.Join(brick.root, "dev", "snd", "controlC0")
+	return &Sound{
+		speaker: f,
+		openPCM: func(cfg pcmConfig) (pcmDevice, error) {
+			return openALSAPCM(pcmPath, cfg)
+		},
+		openVolume: func() (volumeControl, error) {
+			return openALSAVolume(controlPath, pcmVolumeControl)
+		},
+	}, nil
+}
+
+// Close silences the speaker and releases its resources.
+func (snd *Sound) Close() error {
+	snd.mu.Lock()
+	defer snd.mu.Unlock()
+	firstErr := snd.setTone(0)
+	if err := snd.speaker.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr != nil {
+		return fmt.Errorf("close sound: %w", firstErr)
+	}
+	return nil
+}
+
+// Beep plays a short tone. It blocks until the tone finishes.
+func (snd *Sound) Beep() error {
+	if err := snd.Tone(1000, 100*time.Millisecond); err != nil {
+		return fmt.Errorf("beep: %w", err)
+	}
+	return nil
+}
+
+// Tone plays a tone at the given frequency in hertz for the given duration.
+// It blocks until the tone finishes.
+func (snd *Sound) Tone(freq float64, d time.Duration) error {
+	if err := snd.play(context.Background(), []Note{{Frequency: freq, Duration: d}}); err != nil {
+		return fmt.Errorf("play tone: %w", err)
+	}
+	return nil
+}
+
+// Note is a single tone in a melody.
+type Note struct {
+	// Frequency is the pitch of the note in hertz. Zero is a rest.
+	Frequency float64
+	// Duration is how long the note is played.
+	Duration time.Duration
+}
+
+// PlayMelody plays the notes in order. It blocks until the melody finishes
+// or ctx is done, and the speaker is always silenced before it returns.
+func (snd *Sound) PlayMelody(ctx context.Context, notes []Note) error {
+	if err := snd.play(ctx, notes); err != nil {
+		return fmt.Errorf("play melody: %w", err)
+	}
+	return nil
+}
+
+func (snd *Sound) play(ctx context.Context, notes []Note) error {
+	for _, n := range notes {
+		if n.Frequency < 0 || n.Frequency > math.MaxInt32 || math.IsNaN(n.Frequency) {
+			return fmt.Errorf("invalid frequency %v", n.Frequency)
+		}
+	}
+	snd.mu.Lock()
+	defer snd.mu.Unlock()
+	err := snd.playNotes(ctx, notes)
+	if stopErr := snd.setTone(0); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+func (snd *Sound) playNotes(ctx context.Context, notes []Note) error {
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+	for _, n := range notes {
+		if err := snd.setTone(int32(math.Round(n.Frequency))); err != nil {
+			return err
+		}
+		timer.Reset(n.Duration)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// setTone starts a tone at the given frequency or silences the speaker if
+// freq is zero. The caller must be holding onto snd.mu.
+func (snd *Sound) setTone(freq int32) error {
+	if err := writeInputEvent(snd.speaker, evSnd, sndTone, freq); err != nil {
+		return fmt.Errorf("set tone: %w", err)
+	}
+	return nil
+}
+
+// PlayWAV plays a WAV stream containing uncompressed PCM samples. It blocks
+// until the stream finishes playing or ctx is done. The speaker hardware
+// determines which sample rates and channel counts are supported; the EV3
+// plays mono audio.
+func (snd *Sound) PlayWAV(ctx context.Context, r io.Reader) error {
+	hdr, err := readWAVHeader(r)
+	if err != nil {
+		return fmt.Errorf("play wav: %w", err)
+	}
+	if hdr.dataSize >= 0 {
+		r = io.LimitReader(r, hdr.dataSize)
+	}
+	snd.mu.Lock()
+	defer snd.mu.Unlock()
+	pcm, err := snd.openPCM(pcmConfig{
+		channels:      hdr.channels,
+		sampleRate:    hdr.sampleRate,
+		bitsPerSample: hdr.bitsPerSample,
+	})
+	if err != nil {
+		return fmt.Errorf("play wav: %w", err)
+	}
+	err = copyPCM(ctx, pcm, r, hdr.frameSize())
+	if err != nil {
+		pcm.drop()
+	} else {
+		err = pcm.drain()
+	}
+	closeErr := pcm.Close()
+	if err != nil {
+		return fmt.Errorf("play wav: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("play wav: %w", closeErr)
+	}
+	return nil
+}
+
+// copyPCM copies whole frames from r to pcm, checking ctx between writes.
+func copyPCM(ctx context.Context, pcm pcmDevice, r io.Reader, frameSize int) error {
+	buf := make([]byte, (4096/frameSize)*frameSize+frameSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, readErr := io.ReadAtLeast(r, buf, frameSize)
+		n -= n % frameSize
+		if n > 0 {
+			if _, err := pcm.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			// A trailing partial frame is dropped.
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// Volume returns the PCM playback volume as a percentage.
+func (snd *Sound) Volume() (int, error) {
+	v, err := snd.openVolume()
+	if err != nil {
+		return 0, fmt.Errorf("get volume: %w", err)
+	}
+	percent, err := v.volume()
+	v.Close()
+	if err != nil {
+		return 0, fmt.Errorf("get volume: %w", err)
+	}
+	return percent, nil
+}
+
+// SetVolume sets the PCM playback volume as a percentage in the range
+// [0, 100].
+func (snd *Sound) SetVolume(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("set volume: %d%% out of range [0, 100]", percent)
+	}
+	v, err := snd.openVolume()
+	if err != nil {
+		return fmt.Errorf("set volume: %w", err)
+	}
+	err = v.setVolume(percent)
+	closeErr := v.Close()
+	if err != nil {
+		return fmt.Errorf("set volume: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("set volume: %w", closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPlayMelody(t *testing.T) {
+	const speakerPath = "dev/input/by-path/platform-sound-event"
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{speakerPath: ""})
+	snd, err := newBrick(root).OpenSound()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snd.Close()
+
+	notes := []Note{
+		{Frequency: 440, Duration: time.Millisecond},
+		{Frequency: 0, Duration: time.Millisecond},
+		{Frequency: 523.25, Duration: time.Millisecond},
+	}
+	if err := snd.PlayMelody(context.Background(), notes); err != nil {
+		t.Error("PlayMelody:", err)
+	}
+
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(speakerPath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events [8]inputEvent
+	n, err := readInputEvents(f, events[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int32
+	for _, ev := range events[:n] {
+		if ev.typ != evSnd || ev.code != sndTone {
+			t.Errorf("event = {type: %#x, code: %#x}; want {type: %#x, code: %#x}", ev.typ, ev.code, evSnd, sndTone)
+		}
+		got = append(got, ev.value)
+	}
+	want := []int32{440, 0, 523, 0}
+	if len(got) != len(want) {
+		t.Fatalf("tones = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("tones = %v; want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestPlayWAV(t *testing.T) {
+	snd, dir := newFakeSound(t)
+	samples := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	if err := snd.PlayWAV(context.Background(), bytes.NewReader(makeWAV(1, 22050, 16, samples))); err != nil {
+		t.Fatal("PlayWAV:", err)
+	}
+	got := readFile(t, dir, "pcm")
+	// The trailing partial frame is dropped.
+	if want := string(samples[:6]); got != want {
+		t.Errorf("samples played = %q; want %q", got, want)
+	}
+	if got, want := readFile(t, dir, "pcm.config"), "1 22050 16"; got != want {
+		t.Errorf("pcm config = %q; want %q", got, want)
+	}
+}
+
+func TestVolume(t *testing.T) {
+	snd, dir := newFakeSound(t)
+	if err := snd.SetVolume(75); err != nil {
+		t.Fatal("SetVolume(75):", err)
+	}
+	if got := readFile(t, dir, "volume"); got != "75" {
+		t.Errorf("volume file = %q; want \"75\"", got)
+	}
+	if got, err := snd.Volume(); got != 75 || err != nil {
+		t.Errorf("Volume() = %d, %v; want 75, <nil>", got, err)
+	}
+	if err := snd.SetVolume(101); err == nil {
+		t.Error("SetVolume(101) did not return an error")
+	}
+}
+
+// newFakeSound returns a Sound that plays PCM samples into a file named
+// "pcm" and stores its volume in a file named "volume" in the returned
+// directory.
+func newFakeSound(t *testing.T) (*Sound, string) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"dev/input/by-path/platform-sound-event": "",
+	})
+	snd, err := newBrick(root).OpenSound()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { snd.Close() })
+	dir := t.TempDir()
+	snd.openPCM = func(cfg pcmConfig) (pcmDevice, error) {
+		config := fmt.Sprintf("%d %d %d", cfg.channels, cfg.sampleRate, cfg.bitsPerSample)
+		if err := ioutil.WriteFile(filepath.Join(dir, "pcm.config"), []byte(config), 0666); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(filepath.Join(dir, "pcm"))
+		if err != nil {
+			return nil, err
+		}
+		return filePCM{f}, nil
+	}
+	snd.openVolume = func() (volumeControl, error) {
+		return fileVolume(filepath.Join(dir, "volume")), nil
+	}
+	return snd, dir
+}
+
+type filePCM struct {
+	*os.File
+}
+
+func (filePCM) drain() error { return nil }
+func (filePCM) drop() error  { return nil }
+
+type fileVolume string
+
+func (path fileVolume) volume() (int, error) {
+	content, err := ioutil.ReadFile(string(path))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(content))
+}
+
+func (path fileVolume) setVolume(percent int) error {
+	return ioutil.WriteFile(string(path), []byte(strconv.Itoa(percent)), 0666)
+}
+
+func (fileVolume) Close() error { return nil }
@@ -0,0 +1,219 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// MotorState is a set of flags describing what a motor is doing.
+type MotorState uint8
+
+// Motor state flags, as reported by the kernel.
+const (
+	// Power is being sent to the motor.
+	MotorRunning MotorState = 1 << iota
+	// The motor is ramping up or down and has not reached a constant
+	// speed.
+	MotorRamping
+	// The motor is not turning, but is actively holding its position.
+	MotorHolding
+	// The motor is not turning when it should be, even at full power.
+	MotorStalled
+	// The motor is running at full power but cannot reach its speed set
+	// point.
+	MotorOverloaded
+)
+
+var motorStateNames = [...]string{"running", "ramping", "holding", "stalled", "overloaded"}
+
+// Has reports whether all of the given flags are set.
+func (state MotorState) Has(flags MotorState) bool {
+	return state&flags == flags
+}
+
+// String returns the names of the set flags separated by "+", like
+// "running+stalled".
+func (state MotorState) String() string {
+	if state == 0 {
+		return "none"
+	}
+	var names []string
+	for i, name := range motorStateNames {
+		if state&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+func parseMotorState(s string) MotorState {
+	var state MotorState
+	for _, field := range strings.Fields(s) {
+		for i, name := range motorStateNames {
+			if field == name {
+				state |= 1 << uint(i)
+				break
+			}
+		}
+	}
+	return state
+}
+
+// State reads the motor's state flags.
+func (m *TachoMotor) State() (MotorState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var state MotorState
+	err := m.retry(func() error {
+		var buf [64]byte
+		n, err := readAttrBytes(m.state, buf[:])
+		if err != nil {
+			return err
+		}
+		state = parseMotorState(string(buf[:n]))
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("read motor state: %w", err)
+	}
+	return state, nil
+}
+
+// StallOptions configures TachoMotor.WatchStall.
+type StallOptions struct {
+	// Interval is how often the motor is checked. Zero means 50ms.
+	Interval time.Duration
+
+	// The motor is considered stalled if its measured speed stays below
+	// SpeedRatio times the commanded speed for at least Duration while it
+	// is running and not ramping. A zero SpeedRatio means 0.2 and a zero
+	// Duration means 250ms. The kernel's stalled and overloaded flags are
+	// always treated as a stall.
+	SpeedRatio float64
+	Duration   time.Duration
+
+	// If Stop is true, the motor is stopped with StopAction before the
+	// callback is called.
+	Stop       bool
+	StopAction StopAction
+}
+
+// StallEvent describes a stalled motor.
+type StallEvent struct {
+	// State is the motor state at the time of the stall.
+	State MotorState
+	// Speed is the measured speed of the motor.
+	Speed TachoSpeed
+	// SetPoint is the commanded speed of the motor.
+	SetPoint TachoSpeed
+}
+
+// stallSample is a single observation of a motor.
+type stallSample struct {
+	state    MotorState
+	speed    TachoSpeed
+	setPoint TachoSpeed
+}
+
+// WatchStall checks the motor at regular intervals and calls f when the
+// motor stalls or is overloaded. f is called again only after the motor has
+// recovered and stalled again. WatchStall blocks until ctx is done or
+// reading from the motor fails.
+//
+// WatchStall can detect a gripper closing on an object as well as a jammed
+// mechanism.
+func (m *TachoMotor) WatchStall(ctx context.Context, opts *StallOptions, f func(StallEvent)) error {
+	var o StallOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Interval <= 0 {
+		o.Interval = 50 * time.Millisecond
+	}
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	err := watchStall(ctx, &o, ticker.C, m.stallSample, func(e StallEvent) {
+		if o.Stop {
+			// Errors stopping the motor are reported by the next sample.
+			m.Stop(o.StopAction)
+		}
+		f(e)
+	})
+	if err != nil {
+		return fmt.Errorf("watch motor stall: %w", err)
+	}
+	return nil
+}
+
+func (m *TachoMotor) stallSample() (stallSample, error) {
+	state, err := m.State()
+	if err != nil {
+		return stallSample{}, err
+	}
+	speed, err := m.Speed()
+	if err != nil {
+		return stallSample{}, err
+	}
+	m.mu.Lock()
+	setPoint := m.lastSpeed
+	m.mu.Unlock()
+	return stallSample{state, speed, setPoint}, nil
+}
+
+// watchStall implements WatchStall. opts.Interval must be positive.
+func watchStall(ctx context.Context, opts *StallOptions, tick <-chan time.Time, sample func() (stallSample, error), f func(StallEvent)) error {
+	ratio := opts.SpeedRatio
+	if ratio <= 0 {
+		ratio = 0.2
+	}
+	duration := opts.Duration
+	if duration <= 0 {
+		duration = 250 * time.Millisecond
+	}
+	// Number of consecutive slow samples before the motor is stalled.
+	needSlow := int(math.Ceil(float64(duration) / float64(opts.Interval)))
+	slow := 0
+	stalled := false
+	for {
+		s, err := sample()
+		if err != nil {
+			return err
+		}
+		flagged := s.state&(MotorStalled|MotorOverloaded) != 0
+		if s.state.Has(MotorRunning) && !s.state.Has(MotorRamping) && !s.state.Has(MotorHolding) && s.setPoint != 0 &&
+			math.Abs(float64(s.speed)) < ratio*math.Abs(float64(s.setPoint)) {
+			slow++
+		} else {
+			slow = 0
+		}
+		now := flagged || slow >= needSlow
+		if now && !stalled {
+			f(StallEvent{State: s.state, Speed: s.speed, SetPoint: s.setPoint})
+		}
+		stalled = now
+		select {
+		case <-tick:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMotorState(t *testing.T) {
+	tests := []struct {
+		s    string
+		want MotorState
+		str  string
+	}{
+		{"", 0, "none"},
+		{"running", MotorRunning, "running"},
+		{"running ramping", MotorRunning | MotorRamping, "running+ramping"},
+		{"running stalled overloaded", MotorRunning | MotorStalled | MotorOverloaded, "running+stalled+overloaded"},
+		{"holding bogus", MotorHolding, "holding"},
+	}
+	for _, test := range tests {
+		got := parseMotorState(test.s)
+		if got != test.want {
+			t.Errorf("parseMotorState(%q) = %v; want %v", test.s, got, test.want)
+		}
+		if s := got.String(); s != test.str {
+			t.Errorf("parseMotorState(%q).String() = %q; want %q", test.s, s, test.str)
+		}
+	}
+}
+
+func TestTachoMotorState(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	writeFiles(t, motorsDir, map[string]string{"motor0/state": "running stalled\n"})
+	got, err := m.State()
+	if want := MotorRunning | MotorStalled; got != want || err != nil {
+		t.Errorf("State() = %v, %v; want %v, <nil>", got, err, want)
+	}
+}
+
+func TestWatchStall(t *testing.T) {
+	const running = MotorRunning
+	samples := []stallSample{
+		{running | MotorRamping, 10, 500},
+		{running, 450, 500},
+		{running, 50, 500},
+		{running, 40, 500},
+		{running, 30, 500}, // stalled for 3 samples
+		{running, 20, 500},
+		{running, 400, 500}, // recovered
+		{running | MotorOverloaded, 400, 500},
+		{running | MotorOverloaded, 400, 500},
+		{MotorHolding, 0, 500},
+		{running, 0, 0},
+	}
+	errDone := errors.New("out of samples")
+	sample := func() (stallSample, error) {
+		if len(samples) == 0 {
+			return stallSample{}, errDone
+		}
+		s := samples[0]
+		samples = samples[1:]
+		return s, nil
+	}
+	tick := make(chan time.Time)
+	close(tick)
+	opts := &StallOptions{
+		Interval:   10 * time.Millisecond,
+		SpeedRatio: 0.2,
+		Duration:   30 * time.Millisecond,
+	}
+	var events []StallEvent
+	err := watchStall(context.Background(), opts, tick, sample, func(e StallEvent) {
+		events = append(events, e)
+	})
+	if !errors.Is(err, errDone) {
+		t.Errorf("watchStall(...) = %v; want %v", err, errDone)
+	}
+	want := []StallEvent{
+		{State: running, Speed: 30, SetPoint: 500},
+		{State: running | MotorOverloaded, Speed: 400, SetPoint: 500},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v; want %+v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events[%d] = %+v; want %+v", i, events[i], want[i])
+		}
+	}
+}
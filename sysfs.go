@@ -42,6 +42,9 @@ type deviceDir struct {
 
 	// Set of previously scanned devices. Always in sorted order.
 	skipped []skipEntry
+
+	// Addresses of devices that have been returned.
+	claimed []address
 }
 
 type skipEntry struct {
@@ -77,6 +80,7 @@ func (d *deviceDir) findByAddress(addr address) (string, error) {
 				continue
 			}
 			d.skipped = append(d.skipped[:skipIndex], d.skipped[skipIndex+1:]...)
+			d.claim(addr)
 			return filepath.Join(d.path, dn.name), nil
 		}
 
@@ -97,11 +101,56 @@ func (d *deviceDir) findByAddress(addr address) (string, error) {
 		}
 		d.n = dn.n
 		if devAddr == addr {
+			d.claim(addr)
 			return filepath.Join(d.path, dn.name), nil
 		}
 		d.skipped = append(d.skipped, skipEntry{dn.n, devAddr})
 	}
-	return "", fmt.Errorf("find device %q: not found", addr)
+	for _, a := range d.claimed {
+		if a == addr {
+			return "", fmt.Errorf("find device %q: %w", addr, ErrPortBusy)
+		}
+	}
+	return "", fmt.Errorf("find device %q: %w", addr, ErrNotFound)
+}
+
+// unclaim forgets that a device with the given address has been returned.
+func (d *deviceDir) unclaim(addr address) {
+	for i, a := range d.claimed {
+		if a == addr {
+			d.claimed = append(d.claimed[:i], d.claimed[i+1:]...)
+			return
+		}
+	}
+}
+
+// release makes a previously returned device available to findByAddress
+// again.
+func (d *deviceDir) release(path string, addr address) {
+	d.unclaim(addr)
+	dn := parseDeviceName(filepath.Base(path), d.prefix)
+	if dn == (deviceName{}) {
+		return
+	}
+	i := sort.Search(len(d.skipped), func(i int) bool {
+		return d.skipped[i].i >= dn.n
+	})
+	if i < len(d.skipped) && d.skipped[i].i == dn.n {
+		return
+	}
+	d.skipped = append(d.skipped, skipEntry{})
+	copy(d.skipped[i+1:], d.skipped[i:])
+	d.skipped[i] = skipEntry{dn.n, addr}
+}
+
+// claim records that a device with the given address has been returned.
+func (d *deviceDir) claim(addr address) {
+	for _, a := range d.claimed {
+		if a == addr {
+			return
+		}
+	}
+	d.claimed = append(d.claimed, addr)
 }
 
 func (d *deviceDir) list() ([]deviceName, error) {
@@ -153,6 +202,7 @@ func readAttrBytes(file io.ReaderAt, p []byte) (n int, err error) {
 	// when pread(2) has an offset of 0.
 	n, err = file.ReadAt(p, 0)
 	if err != nil && !errors.Is(err, io.EOF) {
+		err = wrapErrno(err)
 		name := attrName(file)
 		if name == "" {
 			return n, fmt.Errorf("read attribute: %w", err)
@@ -206,14 +256,46 @@ func readAttrInt(file io.ReaderAt, bits int) (int64, error) {
 	return i, nil
 }
 
+// readOptionalAttrInt reads and parses an integer attribute value from the
+// named file. If the file does not exist, it returns false and no error.
+func readOptionalAttrInt(path string) (_ int64, ok bool, _ error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	i, err := readAttrInt(f, 32)
+	if err != nil {
+		return 0, false, err
+	}
+	return i, true, nil
+}
+
 func openAttrWrite(path string) (*os.File, error) {
 	return os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
 }
 
+// openDeviceAttrWrite opens the named attribute of the device at dir for
+// writing. If the attribute does not exist because the device's directory
+// is gone, the returned error wraps ErrDeviceRemoved.
+func openDeviceAttrWrite(dir, name string) (*os.File, error) {
+	f, err := openAttrWrite(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		if _, statErr := os.Stat(dir); errors.Is(statErr, os.ErrNotExist) {
+			return nil, fmt.Errorf("open attribute %s: %w", name, ErrDeviceRemoved)
+		}
+	}
+	return f, err
+}
+
 // writeAttr writes a sysfs attribute value.
 func writeAttr(file *os.File, p []byte) error {
 	// Needed for fakes.
 	if err := file.Truncate(0); err != nil {
+		err = wrapErrno(err)
 		name := attrName(file)
 		if name == "" {
 			return fmt.Errorf("write attribute: %w", err)
@@ -221,13 +303,15 @@ func writeAttr(file *os.File, p []byte) error {
 		return fmt.Errorf("write attribute %s: %w", name, err)
 	}
 	// sysfs wants all data in a single write, so we need to customize the
-	// interrupted behavior.
+	// interrupted behavior. Writing at offset 0 keeps fakes from
+	// accumulating a hole from previous writes.
 	for {
-		n, err := unix.Write(int(file.Fd()), p)
+		n, err := unix.Pwrite(int(file.Fd()), p, 0)
 		if err == nil {
 			return nil
 		}
 		if n > 0 || !errors.Is(err, unix.EINTR) {
+			err = wrapErrno(err)
 			name := attrName(file)
 			if name == "" {
 				return fmt.Errorf("write attribute: %w", err)
@@ -17,6 +17,7 @@
 package ev3dev
 
 import (
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -91,6 +92,8 @@ func TestFindByAddress(t *testing.T) {
 
 		want    string
 		wantErr bool
+		// If not nil, the error must match wantIs with errors.Is.
+		wantIs error
 	}
 	tests := []struct {
 		name   string
@@ -121,6 +124,7 @@ func TestFindByAddress(t *testing.T) {
 				},
 				addr:    "iface:S4",
 				wantErr: true,
+				wantIs:  ErrNotFound,
 			}},
 		},
 		{
@@ -151,6 +155,7 @@ func TestFindByAddress(t *testing.T) {
 				{
 					addr:    "iface:S1",
 					wantErr: true,
+					wantIs:  ErrPortBusy,
 				},
 			},
 		},
@@ -253,6 +258,9 @@ func TestFindByAddress(t *testing.T) {
 					}
 					t.Errorf("findByAddress(%q) #%d = %q, %v; want %q, %s", call.addr, i+1, got, err, want, errStr)
 				}
+				if call.wantIs != nil && !errors.Is(err, call.wantIs) {
+					t.Errorf("findByAddress(%q) #%d error = %v; want to match %v", call.addr, i+1, err, call.wantIs)
+				}
 			}
 		})
 	}
@@ -270,3 +278,64 @@ func tempFile(tb testing.TB) *os.File {
 	})
 	return f
 }
+
+// writeFiles creates a tree of files under dir. Keys are slash-separated
+// paths relative to dir.
+func writeFiles(tb testing.TB, dir string, files map[string]string) {
+	tb.Helper()
+	for fname, content := range files {
+		dest := filepath.Join(dir, filepath.FromSlash(fname))
+		if err := os.MkdirAll(filepath.Dir(dest), 0777); err != nil {
+			tb.Fatal(err)
+		}
+		if err := ioutil.WriteFile(dest, []byte(content), 0666); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the content of a file created by writeFiles.
+func readFile(tb testing.TB, dir string, fname string) string {
+	tb.Helper()
+	content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(fname)))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestDeviceDirRelease(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sensor0/address": "iface:S3\n",
+		"sensor1/address": "iface:S1\n",
+		"sensor2/address": "iface:S2\n",
+	})
+	dev := newDeviceDir(dir, "sensor")
+	s1, err := newAddress("iface:S1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := dev.findByAddress(s1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dev.findByAddress(s1); !errors.Is(err, ErrPortBusy) {
+		t.Fatalf("findByAddress(%q) after claim error = %v; want %v", s1, err, ErrPortBusy)
+	}
+	dev.release(path, s1)
+	got, err := dev.findByAddress(s1)
+	if got != path || err != nil {
+		t.Errorf("findByAddress(%q) after release = %q, %v; want %q, <nil>", s1, got, err, path)
+	}
+
+	// Skipped devices are still found.
+	s3, err := newAddress("iface:S3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "sensor0")
+	if got, err := dev.findByAddress(s3); got != want || err != nil {
+		t.Errorf("findByAddress(%q) = %q, %v; want %q, <nil>", s3, got, err, want)
+	}
+}
@@ -17,29 +17,70 @@
 package ev3dev
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // A TachoMotor is a motor with a quadrature encoder.
+//
+// A TachoMotor is safe to use from multiple goroutines. Each method call
+// happens atomically: the set points written by one command are never
+// interleaved with another goroutine's command.
 type TachoMotor struct {
+	// mu protects all of the fields below, including the files' contents.
+	mu sync.Mutex
+	// stopMu protects writes to the command and stop_action files, so that
+	// emergencyStop can stop the motor without waiting for mu. The closed,
+	// command, stopAction and stopActions fields are only changed while
+	// holding both mu and stopMu.
+	stopMu sync.Mutex
+	// stopEpoch counts emergency stops. It is protected by stopMu alone.
+	stopEpoch uint64
+
+	// seq orders motors for locking several at once. It never changes.
+	seq     uint64
+	path    string
+	addr    address
+	devices *devices
+
+	reconnectTimeout time.Duration
+	reconnector      reconnector
+	closed           bool
+	polarity         Polarity
+	limits           TachoMotorLimits
+	// lastSpeed is the last speed set point written, so that commands that
+	// reuse it can be checked against the limits.
+	lastSpeed TachoSpeed
+
 	command          *os.File
 	countPerRot      TachoDelta
+	countPerMeter    TachoDelta
+	fullTravelCount  TachoDelta
 	maxSpeed         TachoSpeed
 	position         *os.File
 	positionSetPoint *os.File
 	speed            *os.File
 	speedSetPoint    *os.File
+	state            *os.File
 	stopAction       *os.File
 	stopActions      [3]bool
 	timeSetPoint     *os.File
 }
 
+// tachoMotorSeq is the last sequence number given to a TachoMotor.
+var tachoMotorSeq uint64
+
 func newTachoMotor(path string) (_ *TachoMotor, err error) {
-	m := new(TachoMotor)
+	m := &TachoMotor{
+		seq:  atomic.AddUint64(&tachoMotorSeq, 1),
+		path: path,
+	}
 	defer func() {
 		if err == nil {
 			return
@@ -53,16 +94,26 @@ func newTachoMotor(path string) (_ *TachoMotor, err error) {
 	if m.command, err = openAttrWrite(filepath.Join(path, "command")); err != nil {
 		return nil, err
 	}
-	countPerRotFile, err := os.Open(filepath.Join(path, "count_per_rot"))
+	// Rotational motors report count_per_rot, while linear actuators report
+	// count_per_m and full_travel_count instead.
+	countPerRot, hasCountPerRot, err := readOptionalAttrInt(filepath.Join(path, "count_per_rot"))
+	if err != nil {
+		return nil, err
+	}
+	m.countPerRot = TachoDelta(countPerRot)
+	countPerMeter, hasCountPerMeter, err := readOptionalAttrInt(filepath.Join(path, "count_per_m"))
 	if err != nil {
 		return nil, err
 	}
-	countPerRot, err := readAttrInt(countPerRotFile, 32)
-	countPerRotFile.Close()
+	m.countPerMeter = TachoDelta(countPerMeter)
+	if !hasCountPerRot && !hasCountPerMeter {
+		return nil, fmt.Errorf("open motor %s: no count_per_rot or count_per_m attribute", path)
+	}
+	fullTravelCount, _, err := readOptionalAttrInt(filepath.Join(path, "full_travel_count"))
 	if err != nil {
 		return nil, err
 	}
-	m.countPerRot = TachoDelta(countPerRot)
+	m.fullTravelCount = TachoDelta(fullTravelCount)
 	maxSpeedFile, err := os.Open(filepath.Join(path, "max_speed"))
 	if err != nil {
 		return nil, err
@@ -85,6 +136,9 @@ func newTachoMotor(path string) (_ *TachoMotor, err error) {
 	if m.speedSetPoint, err = openAttrWrite(filepath.Join(path, "speed_sp")); err != nil {
 		return nil, err
 	}
+	if m.state, err = os.Open(filepath.Join(path, "state")); err != nil {
+		return nil, err
+	}
 	if m.stopAction, err = openAttrWrite(filepath.Join(path, "stop_action")); err != nil {
 		return nil, err
 	}
@@ -120,27 +174,188 @@ func (m *TachoMotor) files() []*os.File {
 		m.positionSetPoint,
 		m.speed,
 		m.speedSetPoint,
+		m.state,
 		m.stopAction,
 		m.timeSetPoint,
 	}
 }
 
-// Reset stops the motor and resets all options.
-func (m *TachoMotor) Reset() error {
-	if err := writeAttr(m.command, []byte("reset")); err != nil {
-		return fmt.Errorf("reset motor: %w", err)
+// SetReconnectTimeout enables automatic reconnection. When the motor is
+// unplugged, the kernel removes its device and operations fail with
+// ErrDeviceRemoved. With reconnection enabled, an operation that fails this
+// way waits up to timeout for a motor to be plugged back into the same port,
+// reopens it, restores its polarity and then retries the operation once.
+// The encoder position starts from zero on the new device, and a command
+// that was running when the motor was unplugged is not resumed.
+//
+// The motor is not locked while waiting, so other goroutines can still
+// stop or close it. Operations that need the device wait for the same
+// reconnect instead of starting another, except for Stop, which fails
+// right away.
+//
+// A timeout of zero (the default) disables reconnection.
+func (m *TachoMotor) SetReconnectTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnectTimeout = timeout
+}
+
+// retry calls f, reconnecting and retrying if f fails because the device was
+// removed and reconnection is enabled. The caller must be holding onto m.mu,
+// which is released while waiting for the motor to be plugged back in.
+func (m *TachoMotor) retry(f func() error) error {
+	if m.devices == nil {
+		return f()
 	}
+	return m.reconnector.retry(&m.mu, m.reconnectTimeout, m.waitForNew(), m.switchTo, f)
+}
+
+// waitForNew returns a function that waits for a new motor at m's address.
+// The caller must be holding onto m.mu, but the returned function can be
+// called without it.
+func (m *TachoMotor) waitForNew() func() (string, error) {
+	devs, addr, timeout := m.devices, m.addr, m.reconnectTimeout
+	return func() (string, error) {
+		return devs.waitForDevice(&devs.tachoMotors, addr, timeout)
+	}
+}
+
+// reconnectSince reconnects an unplugged motor unless it has been
+// reconnected since m.reconnector.n was n. MotorGroup uses it to reconnect a
+// motor without holding the locks of the rest of the group.
+func (m *TachoMotor) reconnectSince(n int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reconnector.n != n {
+		return nil
+	}
+	return m.reconnector.reconnect(&m.mu, m.waitForNew(), m.switchTo)
+}
+
+// switchTo switches the motor's files over to the new motor at path. The
+// caller must be holding onto m.mu.
+func (m *TachoMotor) switchTo(path string) error {
+	if m.closed {
+		// Closed while waiting for the new motor.
+		m.devices.mu.Lock()
+		m.devices.tachoMotors.release(path, m.addr)
+		m.devices.mu.Unlock()
+		return errors.New("motor closed")
+	}
+	nm, err := newTachoMotor(path)
+	if err != nil {
+		return err
+	}
+	if m.polarity != NormalPolarity {
+		if err := writePolarity(path, m.polarity); err != nil {
+			nm.closeFiles()
+			return err
+		}
+	}
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+	m.closeFiles()
+	m.path = nm.path
+	m.command = nm.command
+	m.countPerRot = nm.countPerRot
+	m.countPerMeter = nm.countPerMeter
+	m.fullTravelCount = nm.fullTravelCount
+	m.maxSpeed = nm.maxSpeed
+	m.position = nm.position
+	m.positionSetPoint = nm.positionSetPoint
+	m.speed = nm.speed
+	m.speedSetPoint = nm.speedSetPoint
+	m.state = nm.state
+	m.stopAction = nm.stopAction
+	m.stopActions = nm.stopActions
+	m.timeSetPoint = nm.timeSetPoint
+	m.lastSpeed = 0
 	return nil
 }
 
-// Close stops the motor and cleans up its resources.
-func (m *TachoMotor) Close() error {
-	firstErr := m.Reset()
+func (m *TachoMotor) closeFiles() error {
+	var firstErr error
 	for _, f := range m.files() {
 		if err := f.Close(); err != nil && firstErr == nil {
 			firstErr = err
 		}
 	}
+	return firstErr
+}
+
+// Reset stops the motor and resets all options.
+func (m *TachoMotor) Reset() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.retry(m.reset)
+}
+
+func (m *TachoMotor) reset() error {
+	if err := m.writeCommand("reset"); err != nil {
+		return fmt.Errorf("reset motor: %w", err)
+	}
+	m.lastSpeed = 0
+	// The driver resets the polarity too, so don't restore the old one on
+	// reconnect.
+	m.polarity = NormalPolarity
+	return nil
+}
+
+// SetPolarity sets the direction the motor turns for positive speeds and
+// the direction in which the encoder position increases.
+func (m *TachoMotor) SetPolarity(polarity Polarity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !polarity.isValid() {
+		return fmt.Errorf("set motor polarity: invalid polarity %v", polarity)
+	}
+	err := m.retry(func() error {
+		return writePolarity(m.path, polarity)
+	})
+	if err != nil {
+		return fmt.Errorf("set motor polarity: %w", err)
+	}
+	m.polarity = polarity
+	return nil
+}
+
+func writePolarity(path string, polarity Polarity) error {
+	f, err := openDeviceAttrWrite(path, "polarity")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrUnsupported
+		}
+		return err
+	}
+	err = writeAttr(f, []byte(polarity.String()))
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Close stops the motor, cleans up its resources and lets the motor be
+// opened again from its port. Close does not wait for an unplugged motor to
+// reconnect.
+func (m *TachoMotor) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopMu.Lock()
+	m.closed = true
+	m.stopMu.Unlock()
+	firstErr := m.reset()
+	if m.devices != nil {
+		m.devices.mu.Lock()
+		m.devices.tachoMotors.release(m.path, m.addr)
+		delete(m.devices.openMotors, m)
+		m.devices.mu.Unlock()
+	}
+	m.stopMu.Lock()
+	if err := m.closeFiles(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	m.stopMu.Unlock()
 	if firstErr != nil {
 		return fmt.Errorf("close motor: %w", firstErr)
 	}
@@ -148,12 +363,12 @@ func (m *TachoMotor) Close() error {
 }
 
 // Run instructs the motor to run at the given speed until another command
-// is given.
+// is given. If the motor's limits have a maximum run time, the motor stops
+// after that long.
 func (m *TachoMotor) Run(speed TachoSpeed) error {
-	if err := m.setSpeed(speed); err != nil {
-		return fmt.Errorf("run motor: %w", err)
-	}
-	if err := writeAttr(m.command, []byte("run-forever")); err != nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.retryCommand(func() (string, error) { return m.prepareRun(speed) }); err != nil {
 		return fmt.Errorf("run motor: %w", err)
 	}
 	return nil
@@ -162,13 +377,9 @@ func (m *TachoMotor) Run(speed TachoSpeed) error {
 // RunToPosition instructs the motor to run until it reaches an absolute
 // position then stop.
 func (m *TachoMotor) RunToPosition(pos TachoPosition, params *TachoMotorParams) error {
-	if err := m.setPosition(int32(pos)); err != nil {
-		return fmt.Errorf("run motor to position: %w", err)
-	}
-	if err := m.setParams(params); err != nil {
-		return fmt.Errorf("run motor to position: %w", err)
-	}
-	if err := writeAttr(m.command, []byte("run-to-abs-pos")); err != nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.retryCommand(func() (string, error) { return m.prepareRunToPosition(pos, params) }); err != nil {
 		return fmt.Errorf("run motor to position: %w", err)
 	}
 	return nil
@@ -177,13 +388,9 @@ func (m *TachoMotor) RunToPosition(pos TachoPosition, params *TachoMotorParams)
 // RunToDelta instructs the motor to run until it reaches a position
 // relative to the current position then stop.
 func (m *TachoMotor) RunToDelta(delta TachoDelta, params *TachoMotorParams) error {
-	if err := m.setPosition(int32(delta)); err != nil {
-		return fmt.Errorf("run motor to position: %w", err)
-	}
-	if err := m.setParams(params); err != nil {
-		return fmt.Errorf("run motor to position: %w", err)
-	}
-	if err := writeAttr(m.command, []byte("run-to-rel-pos")); err != nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.retryCommand(func() (string, error) { return m.prepareRunToDelta(delta, params) }); err != nil {
 		return fmt.Errorf("run motor to position: %w", err)
 	}
 	return nil
@@ -191,21 +398,170 @@ func (m *TachoMotor) RunToDelta(delta TachoDelta, params *TachoMotorParams) erro
 
 // RunTimed instructs the motor to run for a set duration then stop.
 func (m *TachoMotor) RunTimed(t time.Duration, params *TachoMotorParams) error {
-	if err := m.setTime(t); err != nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.retryCommand(func() (string, error) { return m.prepareRunTimed(t, params) }); err != nil {
 		return fmt.Errorf("run motor for time: %w", err)
 	}
+	return nil
+}
+
+// The prepare methods write the set points for a command and return the
+// command to write afterward. Splitting commands this way lets MotorGroup
+// start several motors with minimal delay between them. The caller must be
+// holding onto m.mu.
+
+func (m *TachoMotor) prepareRun(speed TachoSpeed) (string, error) {
+	if err := m.setSpeed(speed); err != nil {
+		return "", err
+	}
+	if m.limits.MaxRunTime > 0 {
+		if err := m.setTime(m.limits.MaxRunTime); err != nil {
+			return "", err
+		}
+		return "run-timed", nil
+	}
+	return "run-forever", nil
+}
+
+func (m *TachoMotor) prepareRunToPosition(pos TachoPosition, params *TachoMotorParams) (string, error) {
+	pos, err := m.limitPosition(pos)
+	if err != nil {
+		return "", err
+	}
+	if err := m.setPosition(int32(pos)); err != nil {
+		return "", err
+	}
 	if err := m.setParams(params); err != nil {
-		return fmt.Errorf("run motor for time: %w", err)
+		return "", err
 	}
-	if err := writeAttr(m.command, []byte("run-timed")); err != nil {
-		return fmt.Errorf("run motor for time: %w", err)
+	return "run-to-abs-pos", nil
+}
+
+func (m *TachoMotor) prepareRunToDelta(delta TachoDelta, params *TachoMotorParams) (string, error) {
+	if m.limits.LimitPosition {
+		start, err := readAttrInt(m.position, 32)
+		if err != nil {
+			return "", err
+		}
+		end, err := m.limitPosition(TachoPosition(start).Add(delta))
+		if err != nil {
+			return "", err
+		}
+		delta = end.Sub(TachoPosition(start))
 	}
-	return nil
+	if err := m.setPosition(int32(delta)); err != nil {
+		return "", err
+	}
+	if err := m.setParams(params); err != nil {
+		return "", err
+	}
+	return "run-to-rel-pos", nil
 }
 
-// Stop instructs the motor to stop.
-func (m *TachoMotor) Stop(action StopAction) error {
+func (m *TachoMotor) prepareRunTimed(t time.Duration, params *TachoMotorParams) (string, error) {
+	t, err := m.limitRunTime(t)
+	if err != nil {
+		return "", err
+	}
+	if err := m.setTime(t); err != nil {
+		return "", err
+	}
+	if err := m.setParams(params); err != nil {
+		return "", err
+	}
+	return "run-timed", nil
+}
+
+func (m *TachoMotor) prepareStop(action StopAction) (string, error) {
 	if err := m.setStopAction(action); err != nil {
+		return "", err
+	}
+	return "stop", nil
+}
+
+// runCommand writes a command returned by a prepare method. The caller must
+// be holding onto m.mu.
+func (m *TachoMotor) runCommand(command string, err error) error {
+	if err != nil {
+		return err
+	}
+	return m.writeCommand(command)
+}
+
+// writeCommand writes to the command file. The caller must be holding onto
+// m.mu.
+func (m *TachoMotor) writeCommand(command string) error {
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+	return writeAttr(m.command, []byte(command))
+}
+
+// retryCommand calls prepare and writes the command it returns, retrying as
+// with retry. If the motor is emergency stopped after retryCommand starts,
+// including while waiting for the motor to reconnect, the command is
+// dropped so that it does not start the motor again. The caller must be
+// holding onto m.mu.
+func (m *TachoMotor) retryCommand(prepare func() (string, error)) error {
+	epoch := m.currentStopEpoch()
+	return m.retry(func() error {
+		command, err := prepare()
+		if err != nil {
+			return err
+		}
+		return m.writeCommandSince(command, epoch)
+	})
+}
+
+// currentStopEpoch returns the number of emergency stops so far, to be
+// passed to writeCommandSince.
+func (m *TachoMotor) currentStopEpoch() uint64 {
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+	return m.stopEpoch
+}
+
+// writeCommandSince writes to the command file unless the motor has been
+// emergency stopped since currentStopEpoch returned epoch, in which case it
+// returns an error wrapping ErrEmergencyStopped. The caller must be holding
+// onto m.mu.
+func (m *TachoMotor) writeCommandSince(command string, epoch uint64) error {
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+	if m.stopEpoch != epoch {
+		return fmt.Errorf("command %s: %w", command, ErrEmergencyStopped)
+	}
+	return writeAttr(m.command, []byte(command))
+}
+
+// Stop instructs the motor to stop. Stop never waits for an unplugged motor
+// to reconnect: an unplugged motor is not running, so Stop fails right away
+// with an error wrapping ErrDeviceRemoved.
+func (m *TachoMotor) Stop(action StopAction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop(action)
+}
+
+func (m *TachoMotor) stop(action StopAction) error {
+	if err := m.runCommand(m.prepareStop(action)); err != nil {
+		return fmt.Errorf("stop motor: %w", err)
+	}
+	return nil
+}
+
+// emergencyStop stops the motor while holding only m.stopMu, so that it is
+// not held up by a command in progress or by a reconnect. Commands in
+// progress are dropped instead of starting the motor again. Closed motors
+// are left alone.
+func (m *TachoMotor) emergencyStop(action StopAction) error {
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.stopEpoch++
+	if err := m.writeStopAction(action); err != nil {
 		return fmt.Errorf("stop motor: %w", err)
 	}
 	if err := writeAttr(m.command, []byte("stop")); err != nil {
@@ -215,13 +571,17 @@ func (m *TachoMotor) Stop(action StopAction) error {
 }
 
 func (m *TachoMotor) setSpeed(speed TachoSpeed) error {
-	// TODO(maybe): add safety cap?
+	speed, err := m.limitSpeed(speed)
+	if err != nil {
+		return fmt.Errorf("set motor speed: %w", err)
+	}
 	if speed > m.maxSpeed || speed < -m.maxSpeed {
 		return fmt.Errorf("set motor speed: speed %d beyond max speed %d", speed, m.maxSpeed)
 	}
 	if err := writeAttrInt(m.speedSetPoint, int64(speed)); err != nil {
 		return fmt.Errorf("set motor speed: %w", err)
 	}
+	m.lastSpeed = speed
 	return nil
 }
 
@@ -240,9 +600,20 @@ func (m *TachoMotor) setTime(t time.Duration) error {
 }
 
 func (m *TachoMotor) setStopAction(action StopAction) error {
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+	return m.writeStopAction(action)
+}
+
+// writeStopAction writes to the stop_action file. The caller must be holding
+// onto m.stopMu.
+func (m *TachoMotor) writeStopAction(action StopAction) error {
 	if !action.isValid() {
 		return fmt.Errorf("set motor stop action: invalid action %v", action)
 	}
+	if !m.stopActions[action] {
+		return fmt.Errorf("set motor stop action %v: %w", action, ErrUnsupported)
+	}
 	if err := writeAttr(m.stopAction, []byte(action.String())); err != nil {
 		return fmt.Errorf("set motor stop action: %w", err)
 	}
@@ -254,6 +625,11 @@ func (m *TachoMotor) setParams(params *TachoMotorParams) error {
 		if err := m.setSpeed(params.Speed); err != nil {
 			return err
 		}
+	} else if limited, err := m.limitSpeed(m.lastSpeed); err != nil || limited != m.lastSpeed {
+		// The limits have changed since the last speed was set.
+		if err := m.setSpeed(m.lastSpeed); err != nil {
+			return err
+		}
 	}
 	action := Coast
 	if params != nil {
@@ -267,17 +643,28 @@ func (m *TachoMotor) setParams(params *TachoMotorParams) error {
 
 // MaxSpeed returns the maximum value accepted by the speed commands.
 func (m *TachoMotor) MaxSpeed() TachoSpeed {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.maxSpeed
 }
 
 // CountPerRotation returns the number of tacho counts in one rotation.
+// It returns zero for linear actuators.
 func (m *TachoMotor) CountPerRotation() TachoDelta {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.countPerRot
 }
 
 // Position reads the current value of the encoder.
 func (m *TachoMotor) Position() (TachoPosition, error) {
-	i, err := readAttrInt(m.position, 32)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var i int64
+	err := m.retry(func() (err error) {
+		i, err = readAttrInt(m.position, 32)
+		return err
+	})
 	if err != nil {
 		return 0, fmt.Errorf("read motor position: %w", err)
 	}
@@ -286,7 +673,13 @@ func (m *TachoMotor) Position() (TachoPosition, error) {
 
 // Speed reads the current measured speed of the motor.
 func (m *TachoMotor) Speed() (TachoSpeed, error) {
-	i, err := readAttrInt(m.speed, 32)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var i int64
+	err := m.retry(func() (err error) {
+		i, err = readAttrInt(m.speed, 32)
+		return err
+	})
 	if err != nil {
 		return 0, fmt.Errorf("read motor speed: %w", err)
 	}
@@ -358,3 +751,30 @@ func (action StopAction) String() string {
 func (action StopAction) isValid() bool {
 	return action == Coast || action == Brake || action == Hold
 }
+
+// Polarity is the direction a motor turns for positive speeds.
+type Polarity int
+
+// Motor polarities.
+const (
+	// Positive speeds turn the motor clockwise.
+	NormalPolarity Polarity = iota
+	// Positive speeds turn the motor counter-clockwise.
+	InversedPolarity
+)
+
+// String returns the name of the polarity used by the kernel.
+func (polarity Polarity) String() string {
+	switch polarity {
+	case NormalPolarity:
+		return "normal"
+	case InversedPolarity:
+		return "inversed"
+	default:
+		return fmt.Sprintf("Polarity(%d)", int(polarity))
+	}
+}
+
+func (polarity Polarity) isValid() bool {
+	return polarity == NormalPolarity || polarity == InversedPolarity
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTachoMotorConcurrentUse(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, _ := newFakeTachoMotor(t, addr)
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := m.Run(TachoSpeed(i)); err != nil {
+				t.Error("Run:", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			params := &TachoMotorParams{Speed: 500, StopAction: Hold}
+			if err := m.RunToPosition(TachoPosition(i), params); err != nil {
+				t.Error("RunToPosition:", err)
+				return
+			}
+			if err := m.Stop(Brake); err != nil {
+				t.Error("Stop:", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if _, err := m.Position(); err != nil {
+				t.Error("Position:", err)
+				return
+			}
+			m.SetReconnectTimeout(time.Duration(i) * time.Millisecond)
+			m.MaxSpeed()
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import "math"
+
+// Degrees returns the number of tacho counts the motor turns through in the
+// given angle, rounded to the nearest count. It returns zero for linear
+// actuators, which do not rotate.
+func Degrees(m *TachoMotor, deg float64) TachoDelta {
+	return TachoDelta(math.Round(deg * float64(m.CountPerRotation()) / 360))
+}
+
+// Rotations returns the number of tacho counts in the given number of the
+// motor's rotations, rounded to the nearest count. It returns zero for
+// linear actuators.
+func Rotations(m *TachoMotor, rot float64) TachoDelta {
+	return TachoDelta(math.Round(rot * float64(m.CountPerRotation())))
+}
+
+// Meters returns the number of tacho counts in the given distance, rounded
+// to the nearest count. countPerMeter is the number of tacho counts in one
+// meter of travel, like the count_per_m reported by a linear actuator or
+// the counts for a wheel to roll one meter.
+func Meters(countPerMeter float64, meters float64) TachoDelta {
+	return TachoDelta(math.Round(meters * countPerMeter))
+}
+
+// DegreesPerSecond returns the speed of the motor turning at the given
+// angular velocity, rounded to the nearest count per second. It returns zero
+// for linear actuators.
+func DegreesPerSecond(m *TachoMotor, dps float64) TachoSpeed {
+	return TachoSpeed(math.Round(dps * float64(m.CountPerRotation()) / 360))
+}
+
+// RPM returns the speed of the motor turning at the given rotations per
+// minute, rounded to the nearest count per second. It returns zero for
+// linear actuators.
+func RPM(m *TachoMotor, rpm float64) TachoSpeed {
+	return TachoSpeed(math.Round(rpm * float64(m.CountPerRotation()) / 60))
+}
+
+// MetersPerSecond returns the speed for traveling at the given linear
+// velocity, rounded to the nearest count per second. countPerMeter has the
+// same meaning as for Meters.
+func MetersPerSecond(countPerMeter float64, mps float64) TachoSpeed {
+	return TachoSpeed(math.Round(mps * countPerMeter))
+}
+
+// LinearMeters returns the number of tacho counts a linear actuator travels
+// in the given distance, rounded to the nearest count. It returns zero for
+// rotational motors.
+func LinearMeters(m *TachoMotor, meters float64) TachoDelta {
+	return Meters(float64(m.CountPerMeter()), meters)
+}
+
+// LinearMetersPerSecond returns the speed of a linear actuator traveling at
+// the given linear velocity, rounded to the nearest count per second. It
+// returns zero for rotational motors.
+func LinearMetersPerSecond(m *TachoMotor, mps float64) TachoSpeed {
+	return MetersPerSecond(float64(m.CountPerMeter()), mps)
+}
+
+// perCount divides x by count, returning zero instead of an infinity or NaN
+// if the motor does not report count, like the count per rotation of a
+// linear actuator.
+func perCount(x float64, count TachoDelta) float64 {
+	if count == 0 {
+		return 0
+	}
+	return x / float64(count)
+}
+
+// Degrees converts the position to an angle of the motor's shaft. It
+// returns zero for linear actuators.
+func (pos TachoPosition) Degrees(m *TachoMotor) float64 {
+	return perCount(float64(pos)*360, m.CountPerRotation())
+}
+
+// Rotations converts the position to a number of the motor's rotations. It
+// returns zero for linear actuators.
+func (pos TachoPosition) Rotations(m *TachoMotor) float64 {
+	return perCount(float64(pos), m.CountPerRotation())
+}
+
+// Meters converts the position to a linear distance. countPerMeter has the
+// same meaning as for Meters.
+func (pos TachoPosition) Meters(countPerMeter float64) float64 {
+	return float64(pos) / countPerMeter
+}
+
+// LinearMeters converts the position of a linear actuator to meters from
+// position zero. It returns zero for rotational motors.
+func (pos TachoPosition) LinearMeters(m *TachoMotor) float64 {
+	return perCount(float64(pos), m.CountPerMeter())
+}
+
+// Degrees converts the distance to an angle of the motor's shaft. It
+// returns zero for linear actuators.
+func (delta TachoDelta) Degrees(m *TachoMotor) float64 {
+	return perCount(float64(delta)*360, m.CountPerRotation())
+}
+
+// Rotations converts the distance to a number of the motor's rotations. It
+// returns zero for linear actuators.
+func (delta TachoDelta) Rotations(m *TachoMotor) float64 {
+	return perCount(float64(delta), m.CountPerRotation())
+}
+
+// Meters converts the distance to a linear distance. countPerMeter has the
+// same meaning as for Meters.
+func (delta TachoDelta) Meters(countPerMeter float64) float64 {
+	return float64(delta) / countPerMeter
+}
+
+// LinearMeters converts the distance traveled by a linear actuator to
+// meters. It returns zero for rotational motors.
+func (delta TachoDelta) LinearMeters(m *TachoMotor) float64 {
+	return perCount(float64(delta), m.CountPerMeter())
+}
+
+// DegreesPerSecond converts the speed to an angular velocity of the motor's
+// shaft. It returns zero for linear actuators.
+func (speed TachoSpeed) DegreesPerSecond(m *TachoMotor) float64 {
+	return perCount(float64(speed)*360, m.CountPerRotation())
+}
+
+// RPM converts the speed to rotations per minute of the motor's shaft. It
+// returns zero for linear actuators.
+func (speed TachoSpeed) RPM(m *TachoMotor) float64 {
+	return perCount(float64(speed)*60, m.CountPerRotation())
+}
+
+// MetersPerSecond converts the speed to a linear velocity. countPerMeter
+// has the same meaning as for Meters.
+func (speed TachoSpeed) MetersPerSecond(countPerMeter float64) float64 {
+	return float64(speed) / countPerMeter
+}
+
+// LinearMetersPerSecond converts the speed of a linear actuator to meters
+// per second. It returns zero for rotational motors.
+func (speed TachoSpeed) LinearMetersPerSecond(m *TachoMotor) float64 {
+	return perCount(float64(speed), m.CountPerMeter())
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnits(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	root := t.TempDir()
+	motorsDir := filepath.Join(root, "sys", "class", "tacho-motor")
+	files := fakeTachoMotorFiles("motor0", addr, 0)
+	// A count per rotation other than 360 catches mixing up counts and
+	// degrees.
+	files["motor0/count_per_rot"] = "720\n"
+	writeFiles(t, motorsDir, files)
+	m := openFakeTachoMotor(t, newBrick(root), addr)
+
+	deltas := []struct {
+		name string
+		got  TachoDelta
+		want TachoDelta
+	}{
+		{"Degrees(m, 90)", Degrees(m, 90), 180},
+		{"Degrees(m, -45.2)", Degrees(m, -45.2), -90},
+		{"Rotations(m, 1.5)", Rotations(m, 1.5), 1080},
+		{"Meters(2000, 0.25)", Meters(2000, 0.25), 500},
+	}
+	for _, test := range deltas {
+		if test.got != test.want {
+			t.Errorf("%s = %d; want %d", test.name, test.got, test.want)
+		}
+	}
+	speeds := []struct {
+		name string
+		got  TachoSpeed
+		want TachoSpeed
+	}{
+		{"DegreesPerSecond(m, 90)", DegreesPerSecond(m, 90), 180},
+		{"RPM(m, 60)", RPM(m, 60), 720},
+		{"RPM(m, -30)", RPM(m, -30), -360},
+		{"MetersPerSecond(2000, 0.1)", MetersPerSecond(2000, 0.1), 200},
+	}
+	for _, test := range speeds {
+		if test.got != test.want {
+			t.Errorf("%s = %d; want %d", test.name, test.got, test.want)
+		}
+	}
+	floats := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"TachoPosition(180).Degrees(m)", TachoPosition(180).Degrees(m), 90},
+		{"TachoPosition(-1440).Rotations(m)", TachoPosition(-1440).Rotations(m), -2},
+		{"TachoPosition(500).Meters(2000)", TachoPosition(500).Meters(2000), 0.25},
+		{"TachoDelta(360).Degrees(m)", TachoDelta(360).Degrees(m), 180},
+		{"TachoDelta(1080).Rotations(m)", TachoDelta(1080).Rotations(m), 1.5},
+		{"TachoDelta(-200).Meters(2000)", TachoDelta(-200).Meters(2000), -0.1},
+		{"TachoSpeed(180).DegreesPerSecond(m)", TachoSpeed(180).DegreesPerSecond(m), 90},
+		{"TachoSpeed(720).RPM(m)", TachoSpeed(720).RPM(m), 60},
+		{"TachoSpeed(200).MetersPerSecond(2000)", TachoSpeed(200).MetersPerSecond(2000), 0.1},
+	}
+	for _, test := range floats {
+		if math.Abs(test.got-test.want) > 1e-9 {
+			t.Errorf("%s = %g; want %g", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestUnitsLinearActuator(t *testing.T) {
+	root := t.TempDir()
+	motorsDir := filepath.Join(root, "sys", "class", "tacho-motor")
+	writeFiles(t, motorsDir, fakeLinearActuatorFiles("motor0", "ev3-ports:outA"))
+	writeFiles(t, motorsDir, fakeTachoMotorFiles("motor1", "ev3-ports:outB", 0))
+	brick := newBrick(root)
+	actuator := openFakeTachoMotor(t, brick, "ev3-ports:outA")
+	motor := openFakeTachoMotor(t, brick, "ev3-ports:outB")
+
+	if got, want := LinearMeters(actuator, 0.05), TachoDelta(100); got != want {
+		t.Errorf("LinearMeters(actuator, 0.05) = %d; want %d", got, want)
+	}
+	if got, want := LinearMetersPerSecond(actuator, -0.1), TachoSpeed(-200); got != want {
+		t.Errorf("LinearMetersPerSecond(actuator, -0.1) = %d; want %d", got, want)
+	}
+	floats := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"TachoPosition(100).LinearMeters(actuator)", TachoPosition(100).LinearMeters(actuator), 0.05},
+		{"TachoDelta(-20).LinearMeters(actuator)", TachoDelta(-20).LinearMeters(actuator), -0.01},
+		{"TachoSpeed(200).LinearMetersPerSecond(actuator)", TachoSpeed(200).LinearMetersPerSecond(actuator), 0.1},
+
+		// Conversions that don't apply to the motor are zero instead of
+		// infinite or NaN.
+		{"TachoPosition(100).Degrees(actuator)", TachoPosition(100).Degrees(actuator), 0},
+		{"TachoPosition(100).Rotations(actuator)", TachoPosition(100).Rotations(actuator), 0},
+		{"TachoDelta(100).Degrees(actuator)", TachoDelta(100).Degrees(actuator), 0},
+		{"TachoDelta(100).Rotations(actuator)", TachoDelta(100).Rotations(actuator), 0},
+		{"TachoSpeed(100).DegreesPerSecond(actuator)", TachoSpeed(100).DegreesPerSecond(actuator), 0},
+		{"TachoSpeed(100).RPM(actuator)", TachoSpeed(100).RPM(actuator), 0},
+		{"TachoPosition(100).LinearMeters(motor)", TachoPosition(100).LinearMeters(motor), 0},
+		{"TachoDelta(100).LinearMeters(motor)", TachoDelta(100).LinearMeters(motor), 0},
+		{"TachoSpeed(100).LinearMetersPerSecond(motor)", TachoSpeed(100).LinearMetersPerSecond(motor), 0},
+	}
+	for _, test := range floats {
+		// Written this way so that NaN fails.
+		if !(math.Abs(test.got-test.want) <= 1e-9) {
+			t.Errorf("%s = %g; want %g", test.name, test.got, test.want)
+		}
+	}
+	if got := Degrees(actuator, 90); got != 0 {
+		t.Errorf("Degrees(actuator, 90) = %d; want 0", got)
+	}
+	if got := LinearMeters(motor, 1); got != 0 {
+		t.Errorf("LinearMeters(motor, 1) = %d; want 0", got)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Virtual terminal ioctl requests. See <linux/vt.h> and <linux/kd.h>.
+const (
+	vtOpenQry    = 0x5600
+	vtGetMode    = 0x5601
+	vtSetMode    = 0x5602
+	vtGetState   = 0x5603
+	vtRelDisp    = 0x5605
+	vtActivate   = 0x5606
+	vtWaitActive = 0x5607
+
+	kdSetMode = 0x4b3a
+	kdGetMode = 0x4b3b
+
+	kdGraphics = 1
+
+	vtProcess = 1
+	vtAckAcq  = 2
+)
+
+// vtModeStruct is struct vt_mode.
+type vtModeStruct struct {
+	mode   int8
+	waitv  int8
+	relsig int16
+	acqsig int16
+	frsig  int16
+}
+
+// vtStat is struct vt_stat.
+type vtStat struct {
+	active uint16
+	signal uint16
+	state  uint16
+}
+
+// Signals the kernel sends when another program wants to switch virtual
+// terminals and when the terminal is switched back.
+const (
+	vtReleaseSignal = unix.SIGUSR1
+	vtAcquireSignal = unix.SIGUSR2
+)
+
+// A VT is a virtual terminal that the program has taken over for drawing.
+// While the VT is open, the kernel does not draw its text console on the
+// screen and other programs (like brickman) need the program's cooperation
+// to switch away from it.
+//
+// The previous terminal state is restored when the VT is closed or the
+// program receives SIGINT, SIGTERM or SIGHUP. In the signal case, the
+// signal's default action is then performed, which terminates the program,
+// once the package's other cleanup for the signal (like StopOnSignal) has
+// also finished. See OpenVT for how this affects the program's own signal
+// handling.
+type VT struct {
+	file       *os.File
+	num        int
+	prevActive int
+	prevKDMode int32
+	prevMode   vtModeStruct
+
+	sigs      chan os.Signal
+	stopExit  func()
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	mu        sync.Mutex
+	active    bool
+	onAcquire func()
+	onRelease func()
+}
+
+// OpenVT switches to the virtual terminal with the given number and puts it
+// into graphics mode. If n is zero, OpenVT uses the first unused terminal.
+//
+// Leaving the terminal in graphics mode would leave the console unusable, so
+// until the VT is closed, OpenVT changes how the process handles SIGINT,
+// SIGTERM and SIGHUP: the terminal is restored and then the signal is
+// raised again with its default action. Signals that the program ignores,
+// like SIGHUP under nohup, are left ignored. Raising the signal again also
+// removes the program's own signal.Notify registrations for it, so the
+// program may terminate before its own handler for the signal finishes.
+// OpenVT also uses SIGUSR1 and SIGUSR2 to cooperate with terminal
+// switches.
+func (brick *Brick) OpenVT(n int) (*VT, error) {
+	devDir := filepath.Join(brick.root, "dev")
+	tty0, err := os.OpenFile(filepath.Join(devDir, "tty0"), os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open vt: %w", err)
+	}
+	var state vtStat
+	err = ioctl(tty0, vtGetState, unsafe.Pointer(&state))
+	if err == nil && n == 0 {
+		var free int32
+		err = ioctl(tty0, vtOpenQry, unsafe.Pointer(&free))
+		n = int(free)
+	}
+	tty0.Close()
+	if err != nil {
+		return nil, fmt.Errorf("open vt: %w", err)
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("open vt: no free virtual terminals")
+	}
+
+	f, err := os.OpenFile(filepath.Join(devDir, fmt.Sprintf("tty%d", n)), os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open vt %d: %w", n, err)
+	}
+	vt := &VT{
+		file:       f,
+		num:        n,
+		prevActive: int(state.active),
+		sigs:       make(chan os.Signal, 4),
+		done:       make(chan struct{}),
+		active:     true,
+	}
+	if err := ioctl(f, kdGetMode, unsafe.Pointer(&vt.prevKDMode)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open vt %d: %w", n, err)
+	}
+	if err := ioctl(f, vtGetMode, unsafe.Pointer(&vt.prevMode)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open vt %d: %w", n, err)
+	}
+
+	// Listen for signals before asking the kernel to send them.
+	signal.Notify(vt.sigs, vtReleaseSignal, vtAcquireSignal)
+	vt.stopExit = exitSignals.add(func(os.Signal) {
+		vt.Close()
+	}, unix.SIGINT, unix.SIGTERM, unix.SIGHUP)
+	mode := vtModeStruct{
+		mode:   vtProcess,
+		relsig: int16(vtReleaseSignal),
+		acqsig: int16(vtAcquireSignal),
+	}
+	err = ioctlInt(f, vtActivate, n)
+	if err == nil {
+		err = ioctlInt(f, vtWaitActive, n)
+	}
+	if err == nil {
+		err = ioctl(f, vtSetMode, unsafe.Pointer(&mode))
+	}
+	if err == nil {
+		err = ioctlInt(f, kdSetMode, kdGraphics)
+	}
+	if err != nil {
+		signal.Stop(vt.sigs)
+		vt.stopExit()
+		vt.restore()
+		f.Close()
+		return nil, fmt.Errorf("open vt %d: %w", n, err)
+	}
+	go vt.handleSignals()
+	return vt, nil
+}
+
+// Number returns the terminal's number, as in /dev/ttyN.
+func (vt *VT) Number() int {
+	return vt.num
+}
+
+// Active reports whether the terminal is in the foreground. While the
+// terminal is in the background, drawing to the screen would disturb
+// another program.
+func (vt *VT) Active() bool {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.active
+}
+
+// setOnAcquire sets a function to call after the terminal is switched back
+// to the foreground.
+func (vt *VT) setOnAcquire(f func()) {
+	vt.mu.Lock()
+	vt.onAcquire = f
+	vt.mu.Unlock()
+}
+
+// setOnRelease sets a function to call after the terminal is marked as
+// inactive and before the screen is handed to another program. Drawing
+// that is in progress must finish before f returns.
+func (vt *VT) setOnRelease(f func()) {
+	vt.mu.Lock()
+	vt.onRelease = f
+	vt.mu.Unlock()
+}
+
+// release gives up the screen when another program asks to switch away.
+func (vt *VT) release() {
+	vt.mu.Lock()
+	vt.active = false
+	f := vt.onRelease
+	vt.mu.Unlock()
+	if f != nil {
+		f()
+	}
+	ioctlInt(vt.file, vtRelDisp, 1)
+}
+
+func (vt *VT) handleSignals() {
+	for {
+		var sig os.Signal
+		select {
+		case sig = <-vt.sigs:
+		case <-vt.done:
+			return
+		}
+		switch sig {
+		case vtReleaseSignal:
+			vt.release()
+		case vtAcquireSignal:
+			ioctlInt(vt.file, vtRelDisp, vtAckAcq)
+			vt.mu.Lock()
+			vt.active = true
+			f := vt.onAcquire
+			vt.mu.Unlock()
+			if f != nil {
+				f()
+			}
+		}
+	}
+}
+
+// restore returns the terminal to its previous modes and switches back to
+// the previously active terminal.
+func (vt *VT) restore() error {
+	firstErr := ioctlInt(vt.file, kdSetMode, int(vt.prevKDMode))
+	if err := ioctl(vt.file, vtSetMode, unsafe.Pointer(&vt.prevMode)); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if vt.prevActive > 0 && vt.prevActive != vt.num {
+		if err := ioctlInt(vt.file, vtActivate, vt.prevActive); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close restores the terminal's previous state and switches back to the
+// terminal that was active before OpenVT.
+func (vt *VT) Close() error {
+	vt.closeOnce.Do(func() {
+		signal.Stop(vt.sigs)
+		if vt.stopExit != nil {
+			vt.stopExit()
+		}
+		close(vt.done)
+		vt.mu.Lock()
+		vt.active = false
+		vt.mu.Unlock()
+		firstErr := vt.restore()
+		if err := vt.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if firstErr != nil {
+			vt.closeErr = fmt.Errorf("close vt %d: %w", vt.num, firstErr)
+		}
+	})
+	return vt.closeErr
+}
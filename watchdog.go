@@ -0,0 +1,123 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrWatchdogTripped is returned by Watchdog.Feed when the watchdog stopped
+// its motor because it was not fed in time.
+var ErrWatchdogTripped = errors.New("watchdog tripped")
+
+// A Watchdog stops a motor if it is not fed often enough. It guards against
+// a stuck control loop leaving a motor running forever: the loop calls Feed
+// every iteration, and if a call does not come within the timeout, the
+// watchdog stops the motor.
+//
+// A Watchdog is safe to use from multiple goroutines.
+type Watchdog struct {
+	motor   *TachoMotor
+	timeout time.Duration
+	action  StopAction
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	gen     uint64
+	tripped bool
+	stopErr error
+	stopped bool
+}
+
+// NewWatchdog returns a watchdog for m that is armed immediately. If Feed is
+// not called within timeout, the watchdog stops m with the given action.
+// Like EmergencyStop, the watchdog does not wait for commands in progress
+// on other goroutines or for an unplugged motor to reconnect.
+func NewWatchdog(m *TachoMotor, timeout time.Duration, action StopAction) *Watchdog {
+	w := &Watchdog{
+		motor:   m,
+		timeout: timeout,
+		action:  action,
+	}
+	w.mu.Lock()
+	w.arm()
+	w.mu.Unlock()
+	return w
+}
+
+// arm starts a new deadline. The caller must be holding onto w.mu.
+func (w *Watchdog) arm() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.gen++
+	gen := w.gen
+	w.timer = time.AfterFunc(w.timeout, func() { w.trip(gen) })
+}
+
+func (w *Watchdog) trip(gen uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if gen != w.gen || w.stopped {
+		// Fed or stopped after the timer fired.
+		return
+	}
+	w.tripped = true
+	w.stopErr = w.motor.emergencyStop(w.action)
+}
+
+// Feed pushes back the deadline by the watchdog's timeout. If the watchdog
+// has stopped the motor since the last call to Feed, Feed rearms it and
+// returns an error wrapping ErrWatchdogTripped, so that the caller can
+// decide whether to restart the motor.
+func (w *Watchdog) Feed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return errors.New("feed watchdog: stopped")
+	}
+	w.arm()
+	if !w.tripped {
+		return nil
+	}
+	w.tripped = false
+	stopErr := w.stopErr
+	w.stopErr = nil
+	if stopErr != nil {
+		return fmt.Errorf("feed watchdog: %w (stopping motor: %v)", ErrWatchdogTripped, stopErr)
+	}
+	return fmt.Errorf("feed watchdog: %w", ErrWatchdogTripped)
+}
+
+// Tripped reports whether the watchdog has stopped the motor since the last
+// call to Feed.
+func (w *Watchdog) Tripped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.tripped
+}
+
+// Stop disarms the watchdog without stopping the motor.
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	if err := m.Run(100); err != nil {
+		t.Fatal(err)
+	}
+
+	const timeout = 50 * time.Millisecond
+	w := NewWatchdog(m, timeout, Brake)
+	defer w.Stop()
+	for i := 0; i < 10; i++ {
+		time.Sleep(timeout / 10)
+		if err := w.Feed(); err != nil {
+			t.Fatalf("Feed #%d: %v", i+1, err)
+		}
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "run-forever"; got != want {
+		t.Fatalf("command after feeding = %q; want %q", got, want)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !w.Tripped() {
+		if time.Now().After(deadline) {
+			t.Fatal("watchdog did not trip")
+		}
+		time.Sleep(timeout / 10)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "stop"; got != want {
+		t.Errorf("command after lapse = %q; want %q", got, want)
+	}
+	if got, want := readFile(t, motorsDir, "motor0/stop_action"), "brake"; got != want {
+		t.Errorf("stop_action after lapse = %q; want %q", got, want)
+	}
+	if err := w.Feed(); !errors.Is(err, ErrWatchdogTripped) {
+		t.Errorf("Feed after lapse = %v; want %v", err, ErrWatchdogTripped)
+	}
+	if w.Tripped() {
+		t.Error("Tripped() = true after Feed")
+	}
+}
+
+func TestWatchdogDoesNotWaitForLock(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	if err := m.Run(100); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a command or reconnect in progress on another goroutine.
+	m.mu.Lock()
+	const timeout = 10 * time.Millisecond
+	w := NewWatchdog(m, timeout, Brake)
+	defer w.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for !w.Tripped() {
+		if time.Now().After(deadline) {
+			m.mu.Unlock()
+			t.Fatal("watchdog did not trip while the motor was locked")
+		}
+		time.Sleep(timeout)
+	}
+	m.mu.Unlock()
+	if got, want := readFile(t, motorsDir, "motor0/command"), "stop"; got != want {
+		t.Errorf("command after lapse = %q; want %q", got, want)
+	}
+}
+
+func TestWatchdogStop(t *testing.T) {
+	const addr = "ev3-ports:outA"
+	m, motorsDir := newFakeTachoMotor(t, addr)
+	if err := m.Run(100); err != nil {
+		t.Fatal(err)
+	}
+
+	const timeout = 10 * time.Millisecond
+	w := NewWatchdog(m, timeout, Brake)
+	w.Stop()
+	time.Sleep(5 * timeout)
+	if w.Tripped() {
+		t.Error("Tripped() = true after Stop")
+	}
+	if got, want := readFile(t, motorsDir, "motor0/command"), "run-forever"; got != want {
+		t.Errorf("command = %q; want %q", got, want)
+	}
+	if err := w.Feed(); err == nil {
+		t.Error("Feed after Stop did not return an error")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Ross Light
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ev3dev
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// WAV format tags.
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatExtensible = 0xfffe
+)
+
+// wavHeader is the information from a WAV file needed to play its samples.
+type wavHeader struct {
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+
+	// dataSize is the number of bytes of sample data or -1 if the size is
+	// unknown (as written by streaming encoders).
+	dataSize int64
+}
+
+// frameSize returns the number of bytes in a single frame.
+func (hdr *wavHeader) frameSize() int {
+	return hdr.channels * ((hdr.bitsPerSample + 7) / 8)
+}
+
+// readWAVHeader reads a RIFF WAVE header up to the start of the sample data.
+func readWAVHeader(r io.Reader) (*wavHeader, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("read wav header: %w", unexpectedEOF(err))
+	}
+	if string(riff[:4]) != "RIFF" || string(riff[8:]) != "WAVE" {
+		return nil, errors.New("read wav header: not a WAV file")
+	}
+	var hdr *wavHeader
+	for {
+		var chunkHeader [8]byte
+		if _, err := io.ReadFull(r, chunkHeader[:]); err != nil {
+			return nil, fmt.Errorf("read wav header: %w", unexpectedEOF(err))
+		}
+		id := string(chunkHeader[:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:]))
+		switch id {
+		case "fmt ":
+			var err error
+			hdr, err = readWAVFormat(io.LimitReader(r, size), size)
+			if err != nil {
+				return nil, fmt.Errorf("read wav header: %w", err)
+			}
+			if size%2 == 1 {
+				if _, err := io.CopyN(ioutil.Discard, r, 1); err != nil {
+					return nil, fmt.Errorf("read wav header: %w", unexpectedEOF(err))
+				}
+			}
+		case "data":
+			if hdr == nil {
+				return nil, errors.New("read wav header: data before format")
+			}
+			hdr.dataSize = size
+			if size == 0 || size == 0xffffffff {
+				hdr.dataSize = -1
+			}
+			return hdr, nil
+		default:
+			// Chunks are padded to an even number of bytes.
+			if _, err := io.CopyN(ioutil.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("read wav header: %w", unexpectedEOF(err))
+			}
+		}
+	}
+}
+
+func readWAVFormat(r io.Reader, size int64) (*wavHeader, error) {
+	if size < 16 {
+		return nil, fmt.Errorf("format chunk too short (%d bytes)", size)
+	}
+	var buf [40]byte
+	n := size
+	if n > int64(len(buf)) {
+		n = int64(len(buf))
+	}
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	// Skip any extension fields we don't use.
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return nil, err
+	}
+	tag := binary.LittleEndian.Uint16(buf[0:])
+	if tag == wavFormatExtensible && n >= 26 {
+		// The sub-format GUID starts with the real format tag.
+		tag = binary.LittleEndian.Uint16(buf[24:])
+	}
+	if tag != wavFormatPCM {
+		return nil, fmt.Errorf("unsupported format %#04x", tag)
+	}
+	hdr := &wavHeader{
+		channels:      int(binary.LittleEndian.Uint16(buf[2:])),
+		sampleRate:    int(binary.LittleEndian.Uint32(buf[4:])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(buf[14:])),
+	}
+	if hdr.channels == 0 {
+		return nil, errors.New("zero channels")
+	}
+	if hdr.sampleRate == 0 {
+		return nil, errors.New("zero sample rate")
+	}
+	switch hdr.bitsPerSample {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported sample size of %d bits", hdr.bitsPerSample)
+	}
+	return hdr, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}